@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// DirectMessage는 direct_messages 테이블의 한 행입니다.
+type DirectMessage struct {
+	bun.BaseModel `bun:"table:direct_messages,alias:dm"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Sender    string    `bun:"sender,notnull"`
+	Recipient string    `bun:"recipient,notnull"`
+	Timestamp time.Time `bun:"timestamp,notnull"`
+	Content   string    `bun:"content,notnull"`
+}
+
+// InsertDirectMessage는 개인 메시지를 저장하고, 생성된 ID를 msg.ID에 채웁니다.
+func InsertDirectMessage(ctx context.Context, db *bun.DB, msg *DirectMessage) error {
+	if _, err := db.NewInsert().Model(msg).Returning("id").Exec(ctx); err != nil {
+		return fmt.Errorf("failed to insert direct message: %w", err)
+	}
+
+	return nil
+}
+
+// GetDirectMessages는 두 사용자 사이의 최근 개인 메시지 limit개를 오래된 순서로 반환합니다.
+// beforeID가 0보다 크면 그 ID보다 오래된 메시지만 읽습니다.
+func GetDirectMessages(ctx context.Context, db *bun.DB, a string, b string, beforeID int64, limit int) ([]DirectMessage, error) {
+	messages := make([]DirectMessage, 0, limit)
+	q := db.NewSelect().
+		Model(&messages).
+		Where("LEAST(sender, recipient) = LEAST(?, ?)", a, b).
+		Where("GREATEST(sender, recipient) = GREATEST(?, ?)", a, b)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	if err := q.
+		OrderExpr("id DESC").
+		Limit(limit).
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load direct messages: %w", err)
+	}
+
+	slices.Reverse(messages)
+
+	return messages, nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Message는 messages 테이블의 한 행입니다.
+type Message struct {
+	bun.BaseModel `bun:"table:messages,alias:m"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Room      string    `bun:"room,notnull"`
+	Timestamp time.Time `bun:"timestamp,notnull"`
+	Username  string    `bun:"username,notnull"`
+	Content   string    `bun:"content,notnull"`
+}
+
+// InsertMessage는 메시지를 저장하고, 생성된 ID를 msg.ID에 채웁니다.
+func InsertMessage(ctx context.Context, db *bun.DB, msg *Message) error {
+	if _, err := db.NewInsert().Model(msg).Returning("id").Exec(ctx); err != nil {
+		return fmt.Errorf("failed to insert message: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecentMessages는 room의 최근 메시지 limit개를 오래된 순서로 반환합니다.
+// beforeID가 0보다 크면 그 ID보다 오래된 메시지만 읽습니다. (스크롤백 페이지 로딩)
+func GetRecentMessages(ctx context.Context, db *bun.DB, room string, beforeID int64, limit int) ([]Message, error) {
+	messages := make([]Message, 0, limit)
+	q := db.NewSelect().
+		Model(&messages).
+		Where("room = ?", room)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	if err := q.
+		OrderExpr("id DESC").
+		Limit(limit).
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+
+	slices.Reverse(messages)
+
+	return messages, nil
+}
+
+// GetMentions는 content에 "@username"이 들어있는 최근 메시지를 최대 limit개, 오래된 순서로 반환합니다.
+// LIKE로 후보만 고르기 때문에 "@username2" 같은 메시지도 포함될 수 있습니다. 정확한 판단은 호출하는 쪽에서 합니다.
+func GetMentions(ctx context.Context, db *bun.DB, username string, limit int) ([]Message, error) {
+	pattern := "%@" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(username) + "%"
+
+	messages := make([]Message, 0, limit)
+	if err := db.NewSelect().
+		Model(&messages).
+		Where("content ILIKE ?", pattern).
+		Where("username <> ?", username).
+		OrderExpr("id DESC").
+		Limit(limit).
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load mentions: %w", err)
+	}
+
+	slices.Reverse(messages)
+
+	return messages, nil
+}
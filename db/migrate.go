@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"sshchat/db/migrations"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+func newMigrator(ctx context.Context, db *bun.DB) (*migrate.Migrator, error) {
+	migrator := migrate.NewMigrator(db, migrations.Migrations)
+	if err := migrator.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to init migrations: %w", err)
+	}
+
+	return migrator, nil
+}
+
+// Migrate applies every pending migration and returns the applied group.
+func Migrate(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	migrator, err := newMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// 여러 인스턴스가 동시에 시작해도 한 번만 적용되도록 잠금을 겁니다.
+	if err := migrator.Lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer func() {
+		_ = migrator.Unlock(ctx)
+	}()
+
+	group, err := migrator.Migrate(ctx)
+	if err != nil {
+		return group, fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	return group, nil
+}
+
+// Rollback reverts the most recently applied migration group.
+func Rollback(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	migrator, err := newMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrator.Lock(ctx); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer func() {
+		_ = migrator.Unlock(ctx)
+	}()
+
+	group, err := migrator.Rollback(ctx)
+	if err != nil {
+		return group, fmt.Errorf("failed to rollback: %w", err)
+	}
+
+	return group, nil
+}
+
+// MigrationStatus returns every known migration along with whether it has been applied.
+func MigrationStatus(ctx context.Context, db *bun.DB) (migrate.MigrationSlice, error) {
+	migrator, err := newMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	return ms, nil
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// 마이그레이션 도입 이전에 만들어진 테이블이 있을 수 있으므로 IF NOT EXISTS를 사용합니다.
+		if _, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS messages (
+				id        BIGSERIAL PRIMARY KEY,
+				room      VARCHAR NOT NULL,
+				timestamp TIMESTAMPTZ NOT NULL,
+				username  VARCHAR NOT NULL,
+				content   VARCHAR NOT NULL
+			)`); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS messages_room_id_idx ON messages (room, id)`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS messages`)
+		return err
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS users (
+				id           BIGSERIAL PRIMARY KEY,
+				username     VARCHAR NOT NULL UNIQUE,
+				fingerprint  VARCHAR NOT NULL,
+				created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+				last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS users`)
+		return err
+	})
+}
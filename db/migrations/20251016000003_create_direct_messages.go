@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS direct_messages (
+				id        BIGSERIAL PRIMARY KEY,
+				sender    VARCHAR NOT NULL,
+				recipient VARCHAR NOT NULL,
+				timestamp TIMESTAMPTZ NOT NULL,
+				content   VARCHAR NOT NULL
+			)`); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, `
+			CREATE INDEX IF NOT EXISTS direct_messages_pair_id_idx
+			ON direct_messages (LEAST(sender, recipient), GREATEST(sender, recipient), id)`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS direct_messages`)
+		return err
+	})
+}
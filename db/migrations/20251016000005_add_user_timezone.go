@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE users
+				ADD COLUMN IF NOT EXISTS timezone    VARCHAR NOT NULL DEFAULT '',
+				ADD COLUMN IF NOT EXISTS time_format VARCHAR NOT NULL DEFAULT ''`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE users
+				DROP COLUMN IF EXISTS timezone,
+				DROP COLUMN IF EXISTS time_format`)
+		return err
+	})
+}
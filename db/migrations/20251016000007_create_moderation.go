@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, `ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR NOT NULL DEFAULT 'member'`); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS sanctions (
+				id          BIGSERIAL PRIMARY KEY,
+				action      VARCHAR NOT NULL,
+				target_type VARCHAR NOT NULL,
+				target      VARCHAR NOT NULL,
+				reason      VARCHAR NOT NULL DEFAULT '',
+				created_by  VARCHAR NOT NULL,
+				created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+				expires_at  TIMESTAMPTZ,
+				lifted_at   TIMESTAMPTZ
+			)`); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `
+			CREATE INDEX IF NOT EXISTS sanctions_target_idx
+			ON sanctions (action, target_type, target) WHERE lifted_at IS NULL`); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS moderation_logs (
+				id         BIGSERIAL PRIMARY KEY,
+				actor      VARCHAR NOT NULL,
+				action     VARCHAR NOT NULL,
+				target     VARCHAR NOT NULL,
+				reason     VARCHAR NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS moderation_logs`); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS sanctions`); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, `ALTER TABLE users DROP COLUMN IF EXISTS role`)
+		return err
+	})
+}
@@ -0,0 +1,7 @@
+package migrations
+
+import "github.com/uptrace/bun/migrate"
+
+// Migrations는 sshchat 스키마 마이그레이션 목록입니다.
+// 각 파일은 "<버전>_<이름>.go" 형식이며 init()에서 스스로 등록합니다.
+var Migrations = migrate.NewMigrations()
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Sanction은 sanctions 테이블의 한 행입니다. Action은 "ban" 또는 "mute",
+// TargetType은 "user", "ip", "key"(공개키 지문) 중 하나입니다.
+type Sanction struct {
+	bun.BaseModel `bun:"table:sanctions,alias:s"`
+
+	ID         int64      `bun:"id,pk,autoincrement"`
+	Action     string     `bun:"action,notnull"`
+	TargetType string     `bun:"target_type,notnull"`
+	Target     string     `bun:"target,notnull"`
+	Reason     string     `bun:"reason,notnull"`
+	CreatedBy  string     `bun:"created_by,notnull"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	ExpiresAt  *time.Time `bun:"expires_at"` // nil이면 영구
+	LiftedAt   *time.Time `bun:"lifted_at"`  // 해제된 시간
+}
+
+// ModerationLog는 moderation_logs 테이블의 한 행입니다. 모든 관리 명령이 기록됩니다.
+type ModerationLog struct {
+	bun.BaseModel `bun:"table:moderation_logs,alias:ml"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Actor     string    `bun:"actor,notnull"`
+	Action    string    `bun:"action,notnull"`
+	Target    string    `bun:"target,notnull"`
+	Reason    string    `bun:"reason,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// activeSanctions는 해제되지 않았고 만료되지 않은 제재만 고릅니다.
+func activeSanctions(q *bun.SelectQuery, action string) *bun.SelectQuery {
+	return q.
+		Where("action = ?", action).
+		Where("lifted_at IS NULL").
+		Where("expires_at IS NULL OR expires_at > now()")
+}
+
+// InsertSanction은 제재를 저장하고, 생성된 ID를 s.ID에 채웁니다.
+func InsertSanction(ctx context.Context, db *bun.DB, s *Sanction) error {
+	if _, err := db.NewInsert().Model(s).Returning("id").Exec(ctx); err != nil {
+		return fmt.Errorf("failed to insert sanction: %w", err)
+	}
+
+	return nil
+}
+
+// FindActiveBan은 username, ip, fingerprint 중 하나라도 걸린 유효한 차단을 찾습니다. 없으면 nil을 반환합니다.
+func FindActiveBan(ctx context.Context, db *bun.DB, username string, ip string, fingerprint string) (*Sanction, error) {
+	ban := new(Sanction)
+	err := activeSanctions(db.NewSelect().Model(ban), "ban").
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				WhereOr("target_type = 'user' AND target = ?", username).
+				WhereOr("target_type = 'ip' AND target = ?", ip).
+				WhereOr("target_type = 'key' AND target = ?", fingerprint)
+		}).
+		OrderExpr("id DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up bans: %w", err)
+	}
+
+	return ban, nil
+}
+
+// GetActiveMutes는 유효한 음소거를 모두 반환합니다.
+func GetActiveMutes(ctx context.Context, db *bun.DB) ([]Sanction, error) {
+	mutes := make([]Sanction, 0)
+	if err := activeSanctions(db.NewSelect().Model(&mutes), "mute").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load mutes: %w", err)
+	}
+
+	return mutes, nil
+}
+
+// LiftSanctions는 target에 걸린 유효한 제재를 해제하고, 해제한 개수를 반환합니다.
+func LiftSanctions(ctx context.Context, db *bun.DB, action string, targetType string, target string) (int64, error) {
+	res, err := db.NewUpdate().
+		Model((*Sanction)(nil)).
+		Set("lifted_at = current_timestamp").
+		Where("action = ?", action).
+		Where("target_type = ?", targetType).
+		Where("target = ?", target).
+		Where("lifted_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lift sanctions: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// InsertModerationLog는 관리 기록을 남깁니다.
+func InsertModerationLog(ctx context.Context, db *bun.DB, log *ModerationLog) error {
+	if _, err := db.NewInsert().Model(log).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to insert moderation log: %w", err)
+	}
+
+	return nil
+}
+
+// GetModerationLogs는 최근 관리 기록 limit개를 오래된 순서로 반환합니다.
+func GetModerationLogs(ctx context.Context, db *bun.DB, limit int) ([]ModerationLog, error) {
+	logs := make([]ModerationLog, 0, limit)
+	if err := db.NewSelect().
+		Model(&logs).
+		OrderExpr("id DESC").
+		Limit(limit).
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load moderation logs: %w", err)
+	}
+
+	slices.Reverse(logs)
+
+	return logs, nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// User는 SSH 공개키 지문에 묶인 사용자 이름입니다.
+type User struct {
+	bun.BaseModel `bun:"table:users,alias:u"`
+
+	ID          int64     `bun:"id,pk,autoincrement"`
+	Username    string    `bun:"username,notnull,unique"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	LastSeenAt  time.Time `bun:"last_seen_at,notnull,default:current_timestamp"`
+
+	// 프로필 설정. 비어있으면 기본값을 사용합니다.
+	Theme      string `bun:"theme,notnull,default:''"`
+	Timezone   string `bun:"timezone,notnull,default:''"` // 비어있으면 GeoIP로 찾은 시간대
+	TimeFormat string `bun:"time_format,notnull,default:''"`
+	Notify     string `bun:"notify,notnull,default:''"`
+
+	Role string `bun:"role,notnull,default:'member'"` // owner, admin, moderator, member
+}
+
+// GetUser는 username으로 사용자를 찾습니다. 등록되지 않은 이름이면 nil을 반환합니다.
+func GetUser(ctx context.Context, db *bun.DB, username string) (*User, error) {
+	user := new(User)
+	err := db.NewSelect().Model(user).Where("username = ?", username).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// ClaimUsername registers username to fingerprint on first use and reports
+// whether username belongs to fingerprint. If two keys race for a new name,
+// ON CONFLICT lets only the first one win.
+func ClaimUsername(ctx context.Context, db *bun.DB, username string, fingerprint string) (bool, error) {
+	now := time.Now()
+	user := &User{
+		Username:    username,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+	}
+	if _, err := db.NewInsert().
+		Model(user).
+		On("CONFLICT (username) DO NOTHING").
+		Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	owner, err := GetUser(ctx, db, username)
+	if err != nil {
+		return false, err
+	}
+	if owner == nil || owner.Fingerprint != fingerprint {
+		return false, nil
+	}
+
+	if _, err := db.NewUpdate().
+		Model(owner).
+		Set("last_seen_at = current_timestamp").
+		WherePK().
+		Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return true, nil
+}
+
+// SetUserTheme은 사용자가 고른 색상 테마를 저장합니다.
+func SetUserTheme(ctx context.Context, db *bun.DB, username string, theme string) error {
+	if _, err := db.NewUpdate().
+		Model((*User)(nil)).
+		Set("theme = ?", theme).
+		Where("username = ?", username).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update theme: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserTimezone은 사용자가 고른 시간대를 저장합니다. 빈 문자열은 GeoIP 시간대를 뜻합니다.
+func SetUserTimezone(ctx context.Context, db *bun.DB, username string, timezone string) error {
+	if _, err := db.NewUpdate().
+		Model((*User)(nil)).
+		Set("timezone = ?", timezone).
+		Where("username = ?", username).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update timezone: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserTimeFormat은 사용자가 고른 시간 표시 형식을 저장합니다.
+func SetUserTimeFormat(ctx context.Context, db *bun.DB, username string, format string) error {
+	if _, err := db.NewUpdate().
+		Model((*User)(nil)).
+		Set("time_format = ?", format).
+		Where("username = ?", username).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update time format: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserNotify는 멘션 알림 방식을 저장합니다.
+func SetUserNotify(ctx context.Context, db *bun.DB, username string, notify string) error {
+	if _, err := db.NewUpdate().
+		Model((*User)(nil)).
+		Set("notify = ?", notify).
+		Where("username = ?", username).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update notify: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserRole은 사용자의 역할을 바꿉니다. 등록되지 않은 사용자이면 false를 반환합니다.
+func SetUserRole(ctx context.Context, db *bun.DB, username string, role string) (bool, error) {
+	res, err := db.NewUpdate().
+		Model((*User)(nil)).
+		Set("role = ?", role).
+		Where("username = ?", username).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to update role: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"log/slog"
 	"net"
+	"net/netip"
 	"os"
-	"slices"
 	"strings"
+	"time"
 
 	"github.com/grafana/loki-client-go/loki"
 	slogloki "github.com/samber/slog-loki/v3"
@@ -19,11 +21,12 @@ import (
 	"github.com/gliderlabs/ssh"
 	"github.com/oschwald/geoip2-golang"
 	"github.com/uptrace/bun"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 var config = utils.GetConfig()
 
-func sessionHandler(s ssh.Session, geoip *geoip2.Reader, pgDb *bun.DB, logger *slog.Logger) {
+func sessionHandler(s ssh.Session, hub *utils.Hub, geoip *geoip2.Reader, pgDb *bun.DB, logger *slog.Logger) {
 	ptyReq, _, isPty := s.Pty()
 	if !isPty {
 		_, _ = fmt.Fprintln(s, "Err: PTY requires. Reconnect with -t option.")
@@ -31,14 +34,12 @@ func sessionHandler(s ssh.Session, geoip *geoip2.Reader, pgDb *bun.DB, logger *s
 		return
 	}
 
-	addr := s.RemoteAddr().String()
-	host, _, err := net.SplitHostPort(addr)
-	if err != nil {
-		host = addr
-	}
-	remote := strings.Trim(host, "[]")
+	remote := remoteHost(s.RemoteAddr())
 	username := s.User()
 
+	// IP 목록은 connCallback에서 이미 확인했습니다.
+	trusted, _ := s.Context().Value(trustedKey).(bool)
+
 	geoStatus := utils.GetIPInfo(remote, geoip)
 	if geoStatus == nil {
 		logger.Info("[sshchat] connected", "user", username, "remote", remote, "country", "UNK", "status", "FORCE DISCONNECT")
@@ -48,26 +49,94 @@ func sessionHandler(s ssh.Session, geoip *geoip2.Reader, pgDb *bun.DB, logger *s
 	} else {
 		logger.Info("[sshchat] connected", "user", username, "remote", remote, "country", geoStatus.Country)
 	}
+	geoStatus.Trusted = trusted
 
-	if slices.Contains(config.CountryBlacklist, geoStatus.Country) {
-		logger.Info("[sshchat] country blacklisted", "user", username, "remote", remote)
-		_, _ = fmt.Fprintf(s, "[system] Your access country is blacklisted. %s\n", geoStatus.Country)
+	if trusted {
+		logger.Info("[sshchat] trusted network", "user", username, "remote", remote)
+	} else if geoStatus.Country == "ZZ" {
+		logger.Info("[sshchat] unknown country blacklisted", "user", username)
+		_, _ = fmt.Fprintf(s, "[system] Unknown country is blacklisted. %s\n", geoStatus.Country)
 		_ = s.Close()
+		return
+	} else if err := config.CountryRuleFor("").Check(geoStatus.Country); err != nil {
+		logger.Info("[sshchat] country rejected", "user", username, "remote", remote, "country", geoStatus.Country, "reason", err)
+		_, _ = fmt.Fprintf(s, "[system] Your access country is not allowed: %v\n", err)
+		_ = s.Close()
+		return
 	}
 
-	if geoStatus.Country == "ZZ" {
-		if strings.HasPrefix(remote, "127") || strings.HasPrefix(remote, "::1") {
-			logger.Info("[sshchat] localhost whitelisted", "user", username)
-		} else {
-			logger.Info("[sshchat] unknown country blacklisted", "user", username)
-			_, _ = fmt.Fprintf(s, "[system] Unknown country is blacklisted. %s\n", geoStatus.Country)
+	if geoStatus.IsAnonymousIP && !trusted {
+		policy := config.AnonymousPolicy
+		logger.Info("[sshchat] anonymous ip "+policy.Decision(), "user", username, "remote", remote, "kinds", strings.Join(geoStatus.AnonymousKinds, ","), "policy", string(policy), "reason", "server policy")
+		if policy == utils.PolicyDeny {
+			_, _ = fmt.Fprintln(s, "[system] Connections from anonymous networks (VPN, proxy, Tor) are not allowed.")
 			_ = s.Close()
+			return
+		}
+	}
+
+	if s.PublicKey() == nil {
+		logger.Info("[sshchat] no public key", "user", username, "remote", remote)
+		_, _ = fmt.Fprintln(s, "[system] Public key authentication is required.")
+		_ = s.Close()
+		return
+	}
+
+	// 사용자 이름은 처음 쓴 키에 영구히 묶이므로 등록하기 전에 확인합니다.
+	if err := utils.ValidateUsername(username); err != nil {
+		logger.Info("[sshchat] invalid username", "user", username, "remote", remote, "reason", err)
+		_, _ = fmt.Fprintf(s, "[system] %v. Reconnect with another username.\n", err)
+		_ = s.Close()
+		return
+	}
+
+	fingerprint := gossh.FingerprintSHA256(s.PublicKey())
+	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
+	ban, err := db.FindActiveBan(ctx, pgDb, username, utils.NormalizeIP(remote), fingerprint)
+	cancel()
+	if err != nil {
+		logger.Error("[sshchat] failed to check bans", "user", username, "remote", remote, "error", err)
+		_, _ = fmt.Fprintln(s, "[system] Failed to look up your account. Try again later.")
+		_ = s.Close()
+		return
+	}
+	if ban != nil {
+		logger.Info("[sshchat] banned", "user", username, "remote", remote, "fingerprint", fingerprint, "ban", ban.ID, "target", ban.TargetType+":"+ban.Target)
+		until := "permanently"
+		if ban.ExpiresAt != nil {
+			until = "until " + ban.ExpiresAt.Format(time.RFC3339)
 		}
+		_, _ = fmt.Fprintf(s, "[system] You are banned %s. %s\n", until, ban.Reason)
+		_ = s.Close()
+		return
 	}
 
-	client := utils.NewClient(s, ptyReq.Window.Height, ptyReq.Window.Width, username, remote)
+	ctx, cancel = context.WithTimeout(s.Context(), 5*time.Second)
+	owned, err := db.ClaimUsername(ctx, pgDb, username, fingerprint)
+	cancel()
+	if err != nil {
+		logger.Error("[sshchat] failed to claim username", "user", username, "remote", remote, "error", err)
+		_, _ = fmt.Fprintln(s, "[system] Failed to look up your account. Try again later.")
+		_ = s.Close()
+		return
+	}
+	if !owned {
+		logger.Info("[sshchat] username owned by another key", "user", username, "remote", remote, "fingerprint", fingerprint)
+		_, _ = fmt.Fprintf(s, "[system] Username %s is registered to a different key. Reconnect with another username.\n", username)
+		_ = s.Close()
+		return
+	}
+
+	client := utils.NewClient(s, ptyReq.Window.Width, ptyReq.Window.Height, username, remote, geoStatus)
+	if err := hub.Register(client); err != nil {
+		logger.Info("[sshchat] failed to join", "user", username, "remote", remote, "error", err)
+		client.Disconnect(err.Error())
+		client.Close()
+		return
+	}
 
 	defer func() {
+		hub.Unregister(client)
 		client.Close()
 		logger.Info("[sshchat] disconnected", "user", username, "remote", remote, "country", geoStatus.Country)
 	}()
@@ -75,6 +144,52 @@ func sessionHandler(s ssh.Session, geoip *geoip2.Reader, pgDb *bun.DB, logger *s
 	client.EventLoop()
 }
 
+type contextKey string
+
+// trustedKey는 connCallback이 확인한 신뢰하는 네트워크 여부를 세션에 넘기는 ssh.Context 키입니다.
+const trustedKey contextKey = "trusted"
+
+// remoteHost는 접속한 주소에서 포트와 IPv6 괄호를 뗀 호스트 부분입니다.
+func remoteHost(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return strings.Trim(host, "[]")
+}
+
+// connCallback은 SSH 핸드셰이크 전에 IP 목록을 확인해서, 막힌 네트워크는 인증과 DB 조회 없이 바로 연결을 끊습니다.
+// 신뢰하는 네트워크인지는 GeoIP 단계에서 쓰도록 ctx에 남깁니다.
+func connCallback(ctx ssh.Context, conn net.Conn, logger *slog.Logger) net.Conn {
+	remote := remoteHost(conn.RemoteAddr())
+	ip, err := netip.ParseAddr(remote)
+	if err != nil {
+		logger.Info("[sshchat] invalid remote address", "remote", remote, "error", err)
+		return nil
+	}
+	trusted, err := config.CheckAddr(ip)
+	if err != nil {
+		logger.Info("[sshchat] address rejected", "remote", remote, "reason", err)
+		return nil
+	}
+
+	ctx.SetValue(trustedKey, trusted)
+	return conn
+}
+
+// publicKeyHandler accepts a key if the requested username is unregistered or
+// registered to that key. Rejecting other keys here lets the ssh client try
+// the next key from its agent instead of failing outright.
+func publicKeyHandler(ctx ssh.Context, key ssh.PublicKey, pgDb *bun.DB, logger *slog.Logger) bool {
+	user, err := db.GetUser(ctx, pgDb, ctx.User())
+	if err != nil {
+		logger.Error("[sshchat] failed to look up user", "user", ctx.User(), "error", err)
+		return false
+	}
+
+	return user == nil || user.Fingerprint == gossh.FingerprintSHA256(key)
+}
+
 func getLogger(lokiHost string, identify string) (*slog.Logger, error) {
 	if lokiHost == "" {
 		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
@@ -106,6 +221,49 @@ func getLogger(lokiHost string, identify string) (*slog.Logger, error) {
 	return logger, nil
 }
 
+func migrateCommand(pgDb *bun.DB, args []string) error {
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s migrate up|down|status", os.Args[0])
+	}
+
+	switch args[0] {
+	case "up":
+		group, err := db.Migrate(ctx, pgDb)
+		if err != nil {
+			return err
+		}
+		if group.IsZero() {
+			fmt.Println("there are no new migrations to run (database is up to date)")
+		} else {
+			fmt.Printf("migrated to %s\n", group)
+		}
+	case "down":
+		group, err := db.Rollback(ctx, pgDb)
+		if err != nil {
+			return err
+		}
+		if group.IsZero() {
+			fmt.Println("there are no groups to roll back")
+		} else {
+			fmt.Printf("rolled back %s\n", group)
+		}
+	case "status":
+		ms, err := db.MigrationStatus(ctx, pgDb)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("migrations: %s\n", ms)
+		fmt.Printf("unapplied migrations: %s\n", ms.Unapplied())
+		fmt.Printf("last migration group: %s\n", ms.LastGroup())
+	default:
+		return fmt.Errorf("unknown migrate command %q: use up, down or status", args[0])
+	}
+
+	return nil
+}
+
 func main() {
 	logger, err := getLogger(config.LokiHost, config.Identify)
 	if err != nil {
@@ -113,18 +271,37 @@ func main() {
 		return
 	}
 
-	geoip, err := utils.GetDB(config.RootPath + "/" + config.Geoip)
+	pgDb, err := db.GetDB(config.PgDsn)
 	if err != nil {
-		logger.Error("Geoip db is error", "error", err)
+		log.Fatalf("DB Connection error: %v", err)
+	}
+
+	// sshchat migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrateCommand(pgDb, os.Args[2:])
+		_ = pgDb.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
-	pgDb, err := db.GetDB(config.PgDsn)
+	group, err := db.Migrate(context.Background(), pgDb)
 	if err != nil {
-		log.Fatalf("DB Connection error: %v", err)
+		log.Fatalf("DB migration error: %v", err)
+	}
+	if !group.IsZero() {
+		logger.Info("Applied migrations", "group", group.String())
+	}
+
+	geoip, err := utils.GetDB(config.RootPath + "/" + config.Geoip)
+	if err != nil {
+		logger.Error("Geoip db is error", "error", err)
+		return
 	}
 
 	port := config.Port
+	hub := utils.NewHub(pgDb, logger, config)
 
 	keys, err := utils.CheckHostKey(config.RootPath)
 	if err != nil {
@@ -144,7 +321,13 @@ func main() {
 	s := &ssh.Server{
 		Addr: ":" + port,
 		Handler: func(s ssh.Session) {
-			sessionHandler(s, geoip, pgDb, logger)
+			sessionHandler(s, hub, geoip, pgDb, logger)
+		},
+		ConnCallback: func(ctx ssh.Context, conn net.Conn) net.Conn {
+			return connCallback(ctx, conn, logger)
+		},
+		PublicKeyHandler: func(ctx ssh.Context, key ssh.PublicKey) bool {
+			return publicKeyHandler(ctx, key, pgDb, logger)
 		},
 	}
 	for _, key := range keys {
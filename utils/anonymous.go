@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// AccessPolicy는 익명 IP(VPN, 프록시, Tor)로 접속한 사용자를 어떻게 대할지 정합니다.
+type AccessPolicy string
+
+const (
+	PolicyAllow    AccessPolicy = "allow"
+	PolicyDeny     AccessPolicy = "deny"
+	PolicyReadOnly AccessPolicy = "readonly" // 접속과 입장은 허용하지만 메시지는 보낼 수 없습니다.
+)
+
+var accessPolicies = []AccessPolicy{PolicyAllow, PolicyDeny, PolicyReadOnly}
+
+// ParseAccessPolicy는 "allow", "deny", "readonly"를 AccessPolicy로 바꿉니다.
+func ParseAccessPolicy(name string) (AccessPolicy, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "read-only" {
+		name = string(PolicyReadOnly)
+	}
+	for _, policy := range accessPolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+
+	return PolicyAllow, fmt.Errorf("unknown access policy %q: use allow, deny or readonly", name)
+}
+
+// Decision은 로그에 남길 정책 적용 결과입니다.
+func (p AccessPolicy) Decision() string {
+	switch p {
+	case PolicyDeny:
+		return "denied"
+	case PolicyReadOnly:
+		return "allowed read-only"
+	default:
+		return "allowed"
+	}
+}
+
+// envAccessPolicy는 환경 변수를 AccessPolicy로 읽습니다. 없으면 def, 잘못된 값이면 경고를 남기고 def를 반환합니다.
+func envAccessPolicy(name string, def AccessPolicy) AccessPolicy {
+	v := os.Getenv(name)
+	if strings.TrimSpace(v) == "" {
+		return def
+	}
+	policy, err := ParseAccessPolicy(v)
+	if err != nil {
+		log.Printf("%s: %v, using %s", name, err, def)
+		return def
+	}
+	return policy
+}
+
+// envRoomPolicies는 "room=policy,room=policy" 형태의 환경 변수를 방별 정책으로 읽습니다.
+// 잘못된 항목은 경고를 남기고 건너뜁니다. 서버 정책 server가 deny이면 익명 IP는 방에 들어가기 전에
+// 접속이 끊기므로, 방별 정책으로 더 느슨하게 할 수 없습니다. 그런 항목도 경고를 남기고 건너뜁니다.
+func envRoomPolicies(name string, server AccessPolicy) map[string]AccessPolicy {
+	policies := make(map[string]AccessPolicy)
+	for _, item := range splitList(os.Getenv(name)) {
+		roomName, policyName, ok := strings.Cut(item, "=")
+		room, err := NormalizeRoomName(roomName)
+		if !ok || err != nil {
+			log.Printf("%s: invalid entry %q, expected room=policy", name, item)
+			continue
+		}
+		policy, err := ParseAccessPolicy(policyName)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			continue
+		}
+		if server == PolicyDeny && policy != PolicyDeny {
+			log.Printf("%s: %s=%s has no effect because ANONYMOUS_POLICY=deny rejects anonymous connections before they join a room", name, room, policy)
+			continue
+		}
+		policies[room] = policy
+	}
+
+	return policies
+}
+
+// AnonymousPolicyFor는 room에서 익명 IP에 적용할 정책입니다.
+// room이 ""(개인 메시지)이거나 따로 정하지 않은 방이면 서버 전체 정책을 따릅니다.
+func (cfg *Config) AnonymousPolicyFor(room string) AccessPolicy {
+	if policy, ok := cfg.RoomAnonymousPolicy[room]; ok {
+		return policy
+	}
+	return cfg.AnonymousPolicy
+}
+
+// anonymousPolicy는 c가 room에서 받는 정책입니다. 익명 IP가 아니면 항상 allow입니다.
+func (h *Hub) anonymousPolicy(c *Client, room string) AccessPolicy {
+	info := c.IPInfo()
+	if info == nil || !info.IsAnonymousIP || info.Trusted || h.config == nil {
+		return PolicyAllow
+	}
+	return h.config.AnonymousPolicyFor(room)
+}
+
+// logAnonymous는 익명 IP에 정책을 적용한 이유를 로그로 남깁니다.
+func (h *Hub) logAnonymous(c *Client, room string, policy AccessPolicy, action string) {
+	scope := "server policy"
+	if _, ok := h.config.RoomAnonymousPolicy[room]; ok {
+		scope = "room policy"
+	}
+	h.logger.Info("[sshchat] anonymous ip "+policy.Decision(),
+		"user", c.Username(), "remote", c.IP(), "room", room, "action", action,
+		"kinds", strings.Join(c.IPInfo().AnonymousKinds, ","), "policy", string(policy), "reason", scope)
+}
+
+// checkAnonymousJoin은 익명 IP를 막는 방이면 입장을 거부합니다.
+func (h *Hub) checkAnonymousJoin(c *Client, room string) error {
+	policy := h.anonymousPolicy(c, room)
+	if policy != PolicyDeny {
+		return nil
+	}
+
+	h.logAnonymous(c, room, policy, "join")
+	return fmt.Errorf("#%s does not allow anonymous connections (VPN, proxy, Tor)", room)
+}
+
+// checkPost는 익명 IP를 읽기 전용으로 두는 곳이면 메시지 전송을 거부합니다. 개인 메시지는 room이 ""입니다.
+func (h *Hub) checkPost(c *Client, room string) error {
+	policy := h.anonymousPolicy(c, room)
+	if policy == PolicyAllow {
+		return nil
+	}
+
+	h.logAnonymous(c, room, policy, "post")
+	if room == "" {
+		return fmt.Errorf("anonymous connections (VPN, proxy, Tor) are read-only here")
+	}
+	return fmt.Errorf("#%s is read-only for anonymous connections (VPN, proxy, Tor)", room)
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccessPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    AccessPolicy
+		wantErr bool
+	}{
+		{in: "allow", want: PolicyAllow},
+		{in: "deny", want: PolicyDeny},
+		{in: "readonly", want: PolicyReadOnly},
+		{in: "read-only", want: PolicyReadOnly},
+		{in: " DENY ", want: PolicyDeny},
+		{in: "block", want: PolicyAllow, wantErr: true},
+		{in: "", want: PolicyAllow, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseAccessPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAccessPolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseAccessPolicy(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnonymousPolicyFor(t *testing.T) {
+	cfg := &Config{
+		AnonymousPolicy: PolicyReadOnly,
+		RoomAnonymousPolicy: map[string]AccessPolicy{
+			"help":  PolicyAllow,
+			"staff": PolicyDeny,
+		},
+	}
+
+	tests := []struct {
+		room string
+		want AccessPolicy
+	}{
+		{room: "", want: PolicyReadOnly},
+		{room: DefaultRoom, want: PolicyReadOnly},
+		{room: "help", want: PolicyAllow},
+		{room: "staff", want: PolicyDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.room, func(t *testing.T) {
+			if got := cfg.AnonymousPolicyFor(tt.room); got != tt.want {
+				t.Fatalf("AnonymousPolicyFor(%q) = %q, want %q", tt.room, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvRoomPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		server AccessPolicy
+		want   map[string]AccessPolicy
+	}{
+		{name: "empty", env: "", server: PolicyAllow, want: map[string]AccessPolicy{}},
+		{
+			name:   "overrides",
+			env:    "help=allow, #Staff=deny,ops=read-only",
+			server: PolicyReadOnly,
+			want:   map[string]AccessPolicy{"help": PolicyAllow, "staff": PolicyDeny, "ops": PolicyReadOnly},
+		},
+		{name: "invalid entries skipped", env: "help,bad room=allow,ops=block,ok=deny", server: PolicyAllow, want: map[string]AccessPolicy{"ok": PolicyDeny}},
+		{
+			name:   "server deny cannot be loosened",
+			env:    "help=allow,ops=readonly,staff=deny",
+			server: PolicyDeny,
+			want:   map[string]AccessPolicy{"staff": PolicyDeny},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_ROOM_POLICY", tt.env)
+			if got := envRoomPolicies("TEST_ROOM_POLICY", tt.server); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("envRoomPolicies(%q, %s) = %v, want %v", tt.env, tt.server, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnonymousReadOnly(t *testing.T) {
+	hub := newTestHub(&Config{
+		AnonymousPolicy:     PolicyReadOnly,
+		RoomAnonymousPolicy: map[string]AccessPolicy{"help": PolicyAllow, "staff": PolicyDeny},
+	})
+	anon := newTestClient("anon", &IpInfo{Country: "KR", IsAnonymousIP: true, AnonymousKinds: []string{"vpn"}})
+	bob := newTestClient("bob", &IpInfo{Country: "KR"})
+	for _, c := range []*Client{anon, bob} {
+		if err := hub.Register(c); err != nil {
+			t.Fatalf("Register(%s): %v", c.Username(), err)
+		}
+	}
+
+	// 읽기 전용이면 대화방 메시지와 /msg 모두 막힙니다.
+	anon.submitLine("hello", false)
+	anon.submitLine("/msg bob psst", false)
+	hub.Flush()
+	if got := chatMessages(bob); len(got) != 0 {
+		t.Fatalf("bob received %q from read-only client", got)
+	}
+
+	// 읽기는 그대로 됩니다.
+	bob.submitLine("hi all", false)
+	hub.Flush()
+	if got := chatMessages(anon); len(got) != 1 || got[0] != "hi all" {
+		t.Fatalf("anon messages = %q, want [\"hi all\"]", got)
+	}
+
+	// 익명이 아닌 사용자는 정책의 영향을 받지 않습니다.
+	if err := hub.checkPost(bob, DefaultRoom); err != nil {
+		t.Fatalf("checkPost(bob) = %v, want nil", err)
+	}
+
+	// 방별 정책이 서버 정책보다 우선합니다.
+	if err := hub.Join(anon, "help"); err != nil {
+		t.Fatalf("Join(help) = %v", err)
+	}
+	if err := hub.checkPost(anon, "help"); err != nil {
+		t.Fatalf("checkPost(help) = %v, want nil", err)
+	}
+	if err := hub.Join(anon, "staff"); err == nil {
+		t.Fatalf("Join(staff) succeeded, want error")
+	}
+	if anon.Room() != "help" {
+		t.Fatalf("room after denied join = %q, want help", anon.Room())
+	}
+
+	// 신뢰하는 네트워크는 익명 IP여도 정책을 받지 않습니다.
+	trusted := newTestClient("office", &IpInfo{IsAnonymousIP: true, Trusted: true})
+	if err := hub.checkPost(trusted, DefaultRoom); err != nil {
+		t.Fatalf("checkPost(trusted) = %v, want nil", err)
+	}
+}
@@ -5,22 +5,34 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
-	"unicode/utf8"
+	"unicode"
 
 	"github.com/gliderlabs/ssh"
+	"github.com/rivo/uniseg"
+	gossh "golang.org/x/crypto/ssh"
 )
 
+// maxClientMessages는 Client 하나가 화면용으로 보관하는 최대 메시지 수입니다.
+const maxClientMessages = 500
+
+// pasteIdleTimeout은 붙여넣기 끝 표시(ESC [201~)를 놓쳤다고 보는 입력 없는 시간입니다.
+const pasteIdleTimeout = 2 * time.Second
+
 type Message struct {
+	ID        int64 // DB에 저장된 메시지의 ID. 저장되지 않은 메시지는 0입니다.
+	Room      string
 	Timestamp time.Time
 	Username  string
 	Content   string
-}
+	System    bool
 
-type Input struct {
-	Buffer []rune
-	MaxLen int
+	// Recipient가 비어있지 않으면 개인 메시지입니다.
+	Recipient string
+
+	seq uint64 // 허브가 전달할 때 붙이는 순번. 저장이 끝난 뒤 화면의 메시지를 찾아 ID를 붙이는 데 씁니다.
 }
 
 type Client struct {
@@ -31,10 +43,46 @@ type Client struct {
 	height   int
 	input    Input
 	messages []Message
+	hub      *Hub
+	room     string
+	peer     string    // 개인 대화 화면에서 대화 중인 상대
+	pasting  bool      // bracketed paste 중이면 Enter를 줄 바꿈으로 넣습니다.
+	pasteAt  time.Time // 붙여넣기로 마지막 글자를 받은 시간
+
+	// Scrollback
+	scroll       int  // 맨 아래에서 위로 스크롤한 줄 수
+	unread       int  // 스크롤한 동안 새로 도착한 메시지 수
+	nearTop      bool // 마지막 렌더링 기준으로 불러온 메시지의 맨 위에 가까운지
+	historyDone  bool // DB에 더 오래된 메시지가 없음
+	loadingOlder bool // 이전 페이지를 불러오는 중
+
+	// 마지막으로 그린 화면
+	screen screen
+
+	// 색상
+	theme  *Theme
+	colors colorLevel // PTY 요청의 TERM으로 정한 색상 지원 수준
+
+	// 시간 표시
+	zone       string // /tz로 고른 시간대. 비어있으면 GeoIP로 찾은 시간대
+	timeFormat string
+	clock      clock
+
+	notify string // 멘션 알림 방식 (notifyModes)
+
+	sidebar bool   // 오른쪽에 접속자 목록을 표시 (좁은 화면에서는 자동으로 숨김)
+	mouse   bool   // 마우스 휠 스크롤 (/mouse)
+	away    string // /away 메시지. 자리에 있으면 빈 문자열
+
+	role        Role
+	closeReason string // 관리자가 세션을 끊을 때 터미널을 복구한 뒤 보여줄 메시지
+
+	lastInput time.Time // 마지막으로 키를 입력한 시간 (/who의 유휴 시간)
 
 	wg       sync.WaitGroup
 	username string
 	ip       string
+	ipInfo   *IpInfo
 
 	// Event channels
 	RenderCh         chan struct{}
@@ -53,7 +101,7 @@ type Client struct {
 
 // NewClient creates a Client bound to an ssh.Session and initial state.
 // It starts background goroutines to watch input, window-size changes, and session close.
-func NewClient(s ssh.Session, w int, h int, username string, ip string) *Client {
+func NewClient(s ssh.Session, w int, h int, username string, ip string, ipInfo *IpInfo) *Client {
 	input := Input{
 		Buffer: make([]rune, 0, 128),
 		MaxLen: 128,
@@ -64,6 +112,7 @@ func NewClient(s ssh.Session, w int, h int, username string, ip string) *Client
 		height:            h,
 		username:          username,
 		ip:                ip,
+		ipInfo:            ipInfo,
 		input:             input,
 		messages:          make([]Message, 0),
 		RenderCh:          make(chan struct{}, 1),
@@ -71,82 +120,29 @@ func NewClient(s ssh.Session, w int, h int, username string, ip string) *Client
 		WinSizeChangedCh:  make(chan struct{}, 1),
 		CloseCh:           make(chan struct{}, 1),
 		renderDebounceDur: 50 * time.Millisecond,
+		lastInput:         time.Now(),
+		sidebar:           true,
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
 
+	// 터미널이 지원하는 만큼만 색을 사용합니다.
+	c.theme = lookupTheme(DefaultTheme)
+	if ptyReq, _, ok := s.Pty(); ok {
+		c.colors = colorLevelOf(ptyReq.Term)
+	}
+	c.timeFormat = DefaultTimeFormat
+	c.updateClockLocked()
+	c.notify = DefaultNotify
+
+	// 대체 화면으로 전환해서 접속을 끊은 뒤 사용자의 셸 화면이 그대로 돌아오게 합니다.
+	c.screen.setup(s)
+
 	// Input watcher (Enter, Ctrl+C, Ctrl+D) and render trigger
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
-		reader := bufio.NewReader(s)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
-
-			r, size, err := reader.ReadRune()
-			if err != nil {
-				if err == io.EOF || isSessionClosedErr(err) {
-					c.emitClose()
-				}
-				return
-			}
-
-			if size == 0 {
-				continue
-			}
-
-			c.mu.Lock()
-			switch r {
-			case '\r', '\n': // **[수정] \r과 \n을 함께 처리**
-				if len(c.input.Buffer) > 0 {
-					c.messages = append(c.messages, Message{
-						Timestamp: time.Now(),
-						Username:  c.username,
-						Content:   string(c.input.Buffer),
-					})
-					c.input.Buffer = c.input.Buffer[:0]
-				}
-				c.mu.Unlock()
-				c.trySend(c.EnterCh)
-				c.TrySendRender()
-			case 0x03: // Ctrl+C
-				c.mu.Unlock()
-				c.emitClose()
-				return
-			case 0x04: // Ctrl+D
-				c.mu.Unlock()
-				c.emitClose()
-				return
-			case '\b', 0x7f: // Backspace (0x08) 또는 Delete (0x7f)
-				if len(c.input.Buffer) > 0 {
-					c.input.Buffer = c.input.Buffer[:len(c.input.Buffer)-1]
-					c.mu.Unlock()
-					c.TrySendRender()
-				} else {
-					c.mu.Unlock()
-				}
-			default:
-				// 출력 불가능한 문자 (제어 문자 등)는 무시
-				if r < 0x20 && r != '\t' {
-					c.mu.Unlock()
-					continue
-				}
-
-				if len(c.input.Buffer) < c.input.MaxLen {
-					// 룬(rune)을 []rune 슬라이스에 추가
-					c.input.Buffer = append(c.input.Buffer, r)
-					c.mu.Unlock()
-					c.TrySendRender()
-				} else {
-					// 버퍼가 꽉 찬 경우 렌더링 요청을 보내지 않습니다.
-					c.mu.Unlock()
-				}
-			}
-		}
+		c.readInput(ctx, bufio.NewReader(s))
 	}()
 
 	// Window size change watcher
@@ -184,118 +180,635 @@ func NewClient(s ssh.Session, w int, h int, username string, ip string) *Client
 	return c
 }
 
+// readInput은 세션에서 읽은 키 입력을 처리합니다. 세션이 닫히거나 Ctrl+C/Ctrl+D를 받으면 반환합니다.
+func (c *Client) readInput(ctx context.Context, reader io.RuneReader) {
+	var esc escapeParser
+	lastCR := false // 붙여넣기의 \r\n을 줄 바꿈 하나로 합치기 위해 사용
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		r, size, err := reader.ReadRune()
+		if err != nil {
+			if err == io.EOF || isSessionClosedErr(err) {
+				c.emitClose()
+			}
+			return
+		}
+
+		if size == 0 {
+			continue
+		}
+
+		// ESC: 화살표, Home/End, Delete 등의 이스케이프 시퀀스
+		if seq, consumed := esc.Feed(r); consumed {
+			if seq != "" {
+				c.handleEscape(seq)
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		c.lastInput = time.Now()
+		if c.pasting && c.lastInput.Sub(c.pasteAt) > pasteIdleTimeout {
+			// 끝 표시를 놓쳤으면 입력이 한동안 멈춘 뒤에 누른 키부터 다시 직접 입력으로 받습니다.
+			c.pasting = false
+		}
+		if c.pasting {
+			c.pasteAt = c.lastInput
+		}
+		pasting := c.pasting
+		if !pasting && r == '\t' {
+			c.mu.Unlock()
+			c.complete()
+			continue
+		}
+		// Tab 이외의 키를 누르면 다음 Tab은 새로 완성을 시작합니다.
+		c.input.ResetCompletion()
+
+		if pasting {
+			// 붙여넣는 동안에는 줄 바꿈과 제어 문자를 명령으로 처리하지 않습니다.
+			// maxPasteLen을 넘는 글자는 끝 표시가 올 때까지 버립니다.
+			changed := false
+			switch {
+			case r == '\n' && lastCR:
+			case r == '\r' || r == '\n':
+				changed = c.input.Paste('\n')
+			case r == '\t' || !unicode.IsControl(r):
+				changed = c.input.Paste(r)
+			}
+			lastCR = r == '\r'
+			c.mu.Unlock()
+
+			if changed {
+				c.TrySendRender()
+			}
+			continue
+		}
+
+		changed := false
+		switch r {
+		case '\r', '\n': // **[수정] \r과 \n을 함께 처리**
+			line, pasted := c.input.Submit()
+			c.mu.Unlock()
+
+			c.submitLine(line, pasted)
+			c.trySend(c.EnterCh)
+			c.TrySendRender()
+			continue
+		case 0x03: // Ctrl+C
+			c.mu.Unlock()
+			c.emitClose()
+			return
+		case 0x04: // Ctrl+D
+			c.mu.Unlock()
+			c.emitClose()
+			return
+		case '\b', 0x7f: // Backspace (0x08) 또는 Delete (0x7f)
+			changed = c.input.Backspace()
+		case 0x01: // Ctrl+A
+			changed = c.input.Home()
+		case 0x05: // Ctrl+E
+			changed = c.input.End()
+		case 0x0b: // Ctrl+K
+			changed = c.input.KillToEnd()
+		case 0x15: // Ctrl+U
+			changed = c.input.KillToStart()
+		case 0x17: // Ctrl+W
+			changed = c.input.DeleteWord()
+		case 0x02: // Ctrl+B
+			c.sidebar = !c.sidebar
+			changed = true
+		default:
+			// 출력 불가능한 문자 (C0/C1 제어 문자, DEL)는 무시
+			// 버퍼가 꽉 찬 경우 렌더링 요청을 보내지 않습니다.
+			if !unicode.IsControl(r) {
+				changed = c.input.Insert(r)
+			}
+		}
+		c.mu.Unlock()
+
+		if changed {
+			c.TrySendRender()
+		}
+	}
+}
+
 // Word wrap을 위한 헬퍼 함수
 // 반환되는 각 문자열은 한 줄의 내용이며, 줄 바꿈 문자는 포함하지 않습니다.
-func calculateMessageLines(header string, content []rune, w int) []string {
-	lines := make([]string, 0)
-	currentLine := ""
-	lineWidth := w
-
-	// 1. 헤더 처리
-	if utf8.RuneCountInString(header) > 0 {
-		currentLine = header
-		lineWidth -= utf8.RuneCountInString(header)
-	}
-
-	// 2. 내용 처리
-	contentIdx := 0
-	for contentIdx < len(content) {
-		remainingContent := content[contentIdx:]
-		spaceLeft := lineWidth - utf8.RuneCountInString(currentLine)
-
-		if len(remainingContent) <= spaceLeft {
-			// 남은 내용이 현재 줄에 모두 들어갈 경우
-			currentLine += string(remainingContent)
-			contentIdx = len(content)
-		} else if spaceLeft > 0 {
-			// 현재 줄에 일부만 넣을 경우
-			toAdd := remainingContent[:spaceLeft]
-			currentLine += string(toAdd)
-			contentIdx += len(toAdd)
-
-			// 줄이 꽉 찼으므로 다음 줄로 넘깁니다.
-			lines = append(lines, currentLine)
-			currentLine = ""
-			lineWidth = w // 다음 줄은 전체 너비
-		} else {
-			// 현재 줄이 꽉 찼거나 (spaceLeft <= 0), 헤더만 있는 경우
-			// 현재 줄을 저장하고 다음 줄로 넘깁니다.
-			lines = append(lines, currentLine)
-			currentLine = ""
-			lineWidth = w // 다음 줄은 전체 너비
+// 가능하면 공백에서 줄을 바꾸고, 한 줄보다 긴 단어(URL 등)만 글자 단위로 자릅니다.
+// 두 번째 줄부터는 헤더 너비만큼 들여써서 본문 첫 줄과 맞춥니다.
+func calculateMessageLines(header string, content string, w int) []string {
+	if w <= 0 {
+		return make([]string, 0)
+	}
+
+	indent := displayWidth(header)
+	if w-indent < minWrapWidth {
+		// 화면이 좁으면 들여쓰기를 포기하고 전체 너비를 사용합니다.
+		indent = 0
+	}
+	lw := &lineWrapper{
+		w:      w,
+		indent: strings.Repeat(" ", indent),
+		lines:  make([]string, 0),
+		empty:  true,
+	}
+
+	lw.writeHard(header)
+	content = strings.ReplaceAll(content, "\t", "    ")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	// 여러 줄 메시지는 원래 줄 바꿈을 지키고, 각 줄을 따로 감쌉니다.
+	for i, paragraph := range strings.Split(content, "\n") {
+		if i > 0 {
+			lw.breakLine()
+		}
+		for paragraph != "" {
+			// 공백 덩어리와 그 뒤의 단어 하나를 떼어냅니다.
+			wordStart := strings.IndexFunc(paragraph, func(r rune) bool { return !unicode.IsSpace(r) })
+			if wordStart < 0 {
+				break // 끝에 남은 공백은 버립니다.
+			}
+			space := paragraph[:wordStart]
+			paragraph = paragraph[wordStart:]
+			if i > 0 && lw.empty {
+				// 붙여넣은 코드의 들여쓰기가 사라지지 않도록 줄 앞의 공백을 그대로 씁니다.
+				lw.writeHard(space)
+				space = ""
+			}
+
+			wordEnd := strings.IndexFunc(paragraph, unicode.IsSpace)
+			if wordEnd < 0 {
+				wordEnd = len(paragraph)
+			}
+			lw.writeWord(space, paragraph[:wordEnd])
+			paragraph = paragraph[wordEnd:]
 		}
 	}
 
 	// 마지막 줄이 비어있지 않으면 추가
-	if currentLine != "" {
-		lines = append(lines, currentLine)
+	if !lw.empty {
+		lw.lines = append(lw.lines, lw.current.String())
 	}
 
-	return lines
+	return lw.lines
+}
+
+// minWrapWidth는 들여쓰기를 적용하기 위해 본문에 남아야 하는 최소 칸 수입니다.
+const minWrapWidth = 20
+
+// lineWrapper는 calculateMessageLines가 줄을 채워 나가는 상태입니다.
+type lineWrapper struct {
+	w       int
+	indent  string
+	lines   []string
+	current strings.Builder
+	width   int
+	empty   bool // 현재 줄에 들여쓰기 외의 내용이 없음
+}
+
+func (lw *lineWrapper) breakLine() {
+	lw.lines = append(lw.lines, strings.TrimRight(lw.current.String(), " "))
+	lw.current.Reset()
+	lw.current.WriteString(lw.indent)
+	lw.width = len(lw.indent)
+	lw.empty = true
+}
+
+// writeWord는 단어가 현재 줄에 들어가면 앞의 공백과 함께 붙이고, 아니면 다음 줄에서 시작합니다.
+// 다음 줄에도 들어가지 않는 긴 단어는 writeHard로 잘라서 씁니다.
+func (lw *lineWrapper) writeWord(space string, word string) {
+	spaceWidth := displayWidth(space)
+	wordWidth := displayWidth(word)
+
+	// 줄의 맨 앞에서는 공백을 버립니다.
+	if lw.empty {
+		space, spaceWidth = "", 0
+	}
+
+	switch {
+	case lw.width+spaceWidth+wordWidth <= lw.w:
+		lw.current.WriteString(space)
+		lw.width += spaceWidth
+		lw.writeHard(word)
+	case wordWidth <= lw.w-len(lw.indent):
+		lw.breakLine()
+		lw.writeHard(word)
+	default:
+		if lw.width+spaceWidth < lw.w {
+			lw.current.WriteString(space)
+			lw.width += spaceWidth
+		} else {
+			lw.breakLine()
+		}
+		lw.writeHard(word)
+	}
+}
+
+// writeHard는 s를 글자(grapheme cluster) 단위로 쓰면서 줄이 가득 차면 줄을 바꿉니다.
+// 두 칸짜리 글자가 줄 끝에 걸치면 통째로 다음 줄로 넘깁니다.
+func (lw *lineWrapper) writeHard(s string) {
+	state := -1
+	for s != "" {
+		var cluster string
+		var width int
+		cluster, s, width, state = uniseg.FirstGraphemeClusterInString(s, state)
+
+		if lw.width+width > lw.w && !lw.empty {
+			lw.breakLine()
+		}
+		lw.current.WriteString(cluster)
+		lw.width += width
+		lw.empty = false
+	}
+}
+
+// sanitizeText는 터미널에 그대로 쓰면 안 되는 제어 문자(C0, DEL, C1)를 공백으로 바꿉니다. 줄 바꿈과 탭은 남깁니다.
+// 입력에서 막기 전에 저장된 메시지와 사용자 이름도 있으므로 화면에 그릴 때 항상 거칩니다.
+func sanitizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r == '\r':
+			return -1
+		case unicode.IsControl(r):
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// formatHeader는 메시지 앞에 붙는 "[시간 사용자] " 머리말을 만듭니다. 시간은 ck의 시간대와 형식을 따릅니다.
+func formatHeader(msg Message, ck clock) string {
+	if msg.System {
+		return "[system] "
+	}
+	if msg.Recipient != "" {
+		return fmt.Sprintf("[%s %s -> %s] ", ck.format(msg.Timestamp), sanitizeText(msg.Username), sanitizeText(msg.Recipient))
+	}
+	return fmt.Sprintf("[%s %s] ", ck.format(msg.Timestamp), sanitizeText(msg.Username))
+}
+
+// messageLines는 메시지 하나를 너비 w에 맞춰 줄 단위로 나눕니다.
+func messageLines(msg Message, w int, ck clock) []string {
+	return calculateMessageLines(formatHeader(msg, ck), sanitizeText(msg.Content), w)
 }
 
 // handleRender는 화면 렌더링을 처리합니다.
 func (c *Client) handleRender() {
 	w, h := c.Size()
 	s := c.Session()
+	if w <= 0 || h < 3 {
+		// 메시지 한 줄과 스크롤 표시줄, 프롬프트를 그릴 공간이 없으면 그리지 않습니다.
+		return
+	}
+
+	// 다른 세션이 브로드캐스트하는 중에도 안전하도록 상태를 복사해 둡니다.
+	c.mu.Lock()
+	messages := make([]Message, len(c.messages))
+	copy(messages, c.messages)
+	buffer := make([]rune, len(c.input.Buffer))
+	copy(buffer, c.input.Buffer)
+	cursor := c.input.Cursor
+	room := c.room
+	peer := c.peer
+	scroll := c.scroll
+	unread := c.unread
+	p := painter{theme: c.theme, level: c.colors, self: c.username, clock: c.clock}
+	hub := c.hub
+	mainW := c.messageWidthLocked()
+	c.mu.Unlock()
 
-	// 1. 화면을 지우고 커서를 맨 위로 이동 (화면을 새로 그릴 준비)
-	fmt.Fprint(s, "\x1b[2J\x1b[H")
+	// 1. 새 프레임을 준비합니다. 실제로 터미널에 쓰는 것은 바뀐 줄뿐입니다.
+	frame := make([]string, h)
 
 	// **[수정] 2. 입력 프롬프트 렌더링 영역 계산**
 	promptLine := h // 프롬프트가 위치할 맨 아래 행
 
 	// **[수정] 3. 메시지 렌더링 (아래에서 위로 스크롤)**
 	maxMessageHeight := promptLine - 1 // 메시지가 출력될 수 있는 최대 행
+	if scroll > 0 {
+		maxMessageHeight-- // 스크롤 표시줄
+	}
 
-	messages := c.messages
-	currentY := maxMessageHeight // 현재 출력할 행 (bottom-up)
+	// 최신 메시지부터 거꾸로 줄을 모읍니다. 스크롤된 줄 + 화면 + 다음 페이지만큼만 계산합니다.
+	need := scroll + maxMessageHeight
+	reversed := make([]string, 0, need)
+	for i := len(messages) - 1; i >= 0 && len(reversed) < need+maxMessageHeight; i-- {
+		lines := p.styleLines(messages[i], messageLines(messages[i], mainW, p.clock))
+		for j := len(lines) - 1; j >= 0; j-- {
+			reversed = append(reversed, lines[j])
+		}
+	}
 
-	// 메시지 인덱스를 역순으로 순회 (최신 메시지가 화면 아래에 위치)
-	for i := len(messages) - 1; i >= 0; i-- {
-		msg := messages[i]
+	// 불러온 메시지보다 더 위로 스크롤할 수 없습니다.
+	maxScroll := max(0, len(reversed)-maxMessageHeight)
+	scroll = min(scroll, maxScroll)
+	c.mu.Lock()
+	c.scroll = min(c.scroll, maxScroll)
+	if c.scroll == 0 {
+		c.unread = 0
+	}
+	c.nearTop = len(reversed) < need+maxMessageHeight
+	c.mu.Unlock()
 
-		header := fmt.Sprintf("[%s %s] ", msg.Timestamp.Format("2006-01-02 15:04:05"), msg.Username)
-		content := []rune(msg.Content)
+	shown := reversed[scroll:min(len(reversed), scroll+maxMessageHeight)]
+	currentY := maxMessageHeight - len(shown) // 현재 출력할 행 (0-based)
+	for i := len(shown) - 1; i >= 0; i-- {
+		frame[currentY] = shown[i]
+		currentY++
+	}
 
-		lines := calculateMessageLines(header, content, w)
-		numLines := len(lines)
+	if scroll > 0 {
+		indicator := "-- more messages below --"
+		if unread > 0 {
+			indicator = fmt.Sprintf("-- %d new messages below --", unread)
+		}
+		frame[promptLine-2] = p.paint(truncateWidth(indicator, mainW), p.theme.Indicator)
+	}
 
-		// 현재 행이 출력될 공간이 부족하면 중단
-		if currentY-numLines < 0 {
-			break
+	// 사이드바는 메시지 영역 오른쪽에 붙이고, 프롬프트 줄은 전체 너비를 사용합니다.
+	if mainW < w {
+		side := sidebarLines(hub, room, promptLine-1, p)
+		for i := 0; i < promptLine-1; i++ {
+			entry := ""
+			if i < len(side) {
+				entry = side[i]
+			}
+			frame[i] = withSidebar(frame[i], mainW, entry, p)
 		}
+	}
+
+	// 4. 입력 프롬프트 렌더링 (화면 맨 아래 행에 출력)
+	prompt := "> "
+	if peer != "" {
+		prompt = fmt.Sprintf("[@%s] > ", peer)
+	} else if room != "" {
+		prompt = fmt.Sprintf("[#%s] > ", room)
+	}
+	// 붙여넣은 여러 줄 입력은 프롬프트 한 줄에 ↵로 표시합니다.
+	line := prompt + promptText(buffer)
+	cursorCol := displayWidth(prompt + promptText(buffer[:cursor]))
 
-		// 커서를 메시지가 시작될 행으로 이동 (currentY + 1은 1-based index)
-		currentY -= numLines
-		fmt.Fprintf(s, "\x1b[%dH", currentY+1)
+	// 커서가 화면 밖으로 나가지 않도록 프롬프트를 가로로 스크롤합니다. (줄 바꿈은 고려하지 않음)
+	if cursorCol > w-1 {
+		var dropped int
+		line, dropped = dropWidth(line, cursorCol-(w-1))
+		cursorCol -= dropped
+	}
+	frame[promptLine-1] = truncateWidth(line, w)
+
+	// 5. 바뀐 줄만 그리고 커서를 입력 위치로 재배치 (promptLine 행, 커서 위치)
+	_ = c.screen.draw(s, w, h, frame, promptLine, cursorCol+1)
+}
+
+// promptText는 입력 버퍼를 프롬프트에 그릴 한 줄짜리 문자열로 바꿉니다.
+func promptText(buffer []rune) string {
+	return strings.ReplaceAll(string(buffer), "\n", "↵")
+}
 
-		// 메시지 출력 (줄 바꿈 포함)
-		for _, line := range lines {
-			fmt.Fprintln(s, line)
+// handleEscape는 readEscapeSequence가 읽은 시퀀스를 편집 동작으로 바꿉니다.
+func (c *Client) handleEscape(seq string) {
+	c.mu.Lock()
+	c.input.ResetCompletion()
+	changed := false
+	switch seq {
+	case "[A", "OA": // Up
+		changed = c.input.HistoryPrev()
+	case "[B", "OB": // Down
+		changed = c.input.HistoryNext()
+	case "[C", "OC": // Right
+		changed = c.input.Right()
+	case "[D", "OD": // Left
+		changed = c.input.Left()
+	case "[H", "OH", "[1~", "[7~": // Home
+		changed = c.input.Home()
+	case "[F", "OF", "[4~", "[8~": // End
+		changed = c.input.End()
+	case "[3~": // Delete
+		changed = c.input.Delete()
+	case "[5~": // PageUp
+		c.mu.Unlock()
+		c.scrollBy(c.pageSize())
+		return
+	case "[6~": // PageDown
+		c.mu.Unlock()
+		c.scrollBy(-c.pageSize())
+		return
+	case "[200~": // bracketed paste 시작
+		c.pasting = true
+		c.pasteAt = time.Now()
+	case "[201~": // bracketed paste 끝
+		c.pasting = false
+		changed = true
+	default:
+		// SGR 마우스 휠: ESC [ < 64 ; x ; y M (위), 65 (아래)
+		if strings.HasPrefix(seq, "[<64;") {
+			c.mu.Unlock()
+			c.scrollBy(3)
+			return
 		}
-		if currentY < 0 {
-			break
+		if strings.HasPrefix(seq, "[<65;") {
+			c.mu.Unlock()
+			c.scrollBy(-3)
+			return
 		}
 	}
+	c.mu.Unlock()
 
-	// 4. 입력 프롬프트 렌더링 (화면 맨 아래 행에 출력)
-	fmt.Fprintf(s, "\x1b[%dH", promptLine)
-	promptRunes := append([]rune("> "), c.input.Buffer...)
+	if changed {
+		c.TrySendRender()
+	}
+}
 
-	// 프롬프트를 화면 너비 w에 맞게 출력 (줄 바꿈은 고려하지 않음)
-	if len(promptRunes) > w {
-		fmt.Fprint(s, string(promptRunes[:w]))
-	} else {
-		fmt.Fprint(s, string(promptRunes))
+// pageSize는 PageUp/PageDown 한 번에 스크롤할 줄 수입니다.
+func (c *Client) pageSize() int {
+	_, h := c.Size()
+	return max(1, h-2)
+}
+
+// scrollBy는 스크롤 위치를 delta줄만큼 위(+)나 아래(-)로 옮깁니다.
+// 불러온 메시지의 맨 위에 가까워지면 DB에서 이전 페이지를 먼저 불러옵니다.
+func (c *Client) scrollBy(delta int) {
+	c.mu.Lock()
+	hub := c.hub
+	loadOlder := hub != nil && delta > 0 && c.nearTop && !c.historyDone && !c.loadingOlder
+	if loadOlder {
+		c.loadingOlder = true
 	}
+	c.mu.Unlock()
 
-	// 5. 마지막으로 커서를 입력 위치로 재배치 (promptLine 행, 프롬프트 문자열 끝)
-	cursorX := utf8.RuneCountInString("> ") + len(c.input.Buffer) + 1
-	if cursorX > w {
-		cursorX = w
+	// DB가 느려도 스크롤과 입력이 멈추지 않도록 따로 불러옵니다. 앞에 붙이므로 보고 있는 위치는 그대로입니다.
+	if loadOlder {
+		go func() {
+			hub.LoadOlder(c)
+			c.mu.Lock()
+			c.loadingOlder = false
+			c.mu.Unlock()
+			c.TrySendRender()
+		}()
 	}
-	fmt.Fprintf(s, "\x1b[%d;%dH", promptLine, cursorX)
+
+	c.mu.Lock()
+	c.scroll = max(0, c.scroll+delta)
+	if c.scroll == 0 {
+		c.unread = 0
+	}
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+// submitLine은 Enter로 입력된 한 줄을 명령, 개인 메시지, 대화방 메시지 중 하나로 처리합니다.
+// 붙여넣은 내용으로 시작하거나 여러 줄인 입력은 "/"로 시작해도 명령이 아닌 메시지로 보냅니다.
+func (c *Client) submitLine(line string, pasted bool) {
+	// 붙여넣은 내용 끝의 빈 줄은 보내지 않습니다.
+	line = strings.TrimRight(line, "\n")
+	if line == "" {
+		return
+	}
+
+	c.mu.Lock()
+	hub := c.hub
+	room := c.room
+	peer := c.peer
+	c.mu.Unlock()
+
+	// 명령도 도배할 수 있으므로 메시지와 같은 제한을 받습니다.
+	if hub != nil && !hub.allowMessage(c) {
+		return
+	}
+
+	if strings.HasPrefix(line, "/") && !pasted && !strings.Contains(line, "\n") {
+		c.handleCommand(line)
+		return
+	}
+
+	msg := Message{
+		Room:      room,
+		Timestamp: time.Now(),
+		Username:  c.username,
+		Content:   line,
+	}
+
+	// 허브에 등록되어 있지 않으면 자기 화면에만 추가합니다.
+	if hub == nil {
+		c.appendMessage(msg)
+		return
+	}
+
+	if peer != "" {
+		msg.Room = ""
+		msg.Recipient = peer
+	}
+	if err := hub.checkPost(c, msg.Room); err != nil {
+		c.systemMessage("%v", err)
+		return
+	}
+
+	if peer != "" {
+		if err := hub.SendDirect(c, msg); err != nil {
+			c.systemMessage("%v", err)
+		}
+		return
+	}
+
+	if err := hub.Broadcast(msg); err != nil {
+		c.systemMessage("%v", err)
+	}
+}
+
+// appendMessage는 화면 버퍼에 메시지를 추가합니다. 오래된 메시지는 잘라냅니다.
+func (c *Client) appendMessage(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.appendMessageLocked(msg)
+}
+
+func (c *Client) appendMessageLocked(msg Message) {
+	c.messages = append(c.messages, msg)
+
+	// 스크롤해서 이전 기록을 보는 중에는 불러온 메시지를 잘라내지 않습니다.
+	if len(c.messages) > maxClientMessages && c.scroll == 0 {
+		c.messages = c.messages[len(c.messages)-maxClientMessages:]
+	}
+
+	// 스크롤 중이면 보고 있는 위치가 밀려 올라가지 않도록 새 줄 수만큼 스크롤 위치를 늘립니다.
+	if c.scroll > 0 && c.width > 0 {
+		c.scroll += len(messageLines(msg, c.messageWidthLocked(), c.clock))
+		c.unread++
+	}
+}
+
+// resetViewLocked는 화면 버퍼를 messages로 바꾸고 스크롤 상태를 초기화합니다.
+func (c *Client) resetViewLocked(messages []Message) {
+	c.messages = messages
+	c.scroll = 0
+	c.unread = 0
+	c.nearTop = false
+	c.historyDone = false
+}
+
+// receive는 허브가 전달한 메시지를 화면에 추가하고 렌더링을 요청합니다.
+// 개인 대화 화면을 보고 있는 동안에는 입퇴장 알림을 포함한 대화방 메시지를 건너뜁니다. 대화방으로 돌아오면 다시 불러옵니다.
+// 나를 부른 메시지는 화면에 보이지 않더라도 알림을 보냅니다.
+func (c *Client) receive(msg Message) {
+	if mentions(msg, c.username) {
+		c.notifyMention(msg)
+	}
+
+	c.mu.Lock()
+	if c.peer != "" && msg.Room != "" {
+		c.mu.Unlock()
+		return
+	}
+	c.appendMessageLocked(msg)
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+// assignID는 화면에 있는 msg에 저장 후 정해진 DB ID를 붙입니다.
+// 그 사이 DB에서 불러온 같은 메시지가 이미 화면에 있으면 먼저 전달받은 쪽을 지웁니다.
+func (c *Client) assignID(msg Message, id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	direct := msg.Recipient != ""
+	idx, loaded := -1, false
+	for i, m := range c.messages {
+		switch {
+		case m.seq == msg.seq && m.ID == 0:
+			idx = i
+		case m.ID == id && (m.Recipient != "") == direct:
+			loaded = true
+		}
+	}
+	if idx < 0 {
+		return
+	}
+	if loaded {
+		c.messages = append(c.messages[:idx], c.messages[idx+1:]...)
+		return
+	}
+	c.messages[idx].ID = id
+}
+
+// systemMessage는 이 Client 화면에만 보이는 [system] 메시지를 추가합니다.
+func (c *Client) systemMessage(format string, args ...any) {
+	c.appendMessage(Message{
+		Timestamp: time.Now(),
+		Username:  "system",
+		Content:   fmt.Sprintf(format, args...),
+		System:    true,
+	})
+	c.TrySendRender()
 }
 
 func (c *Client) handleClose() {
@@ -310,6 +823,164 @@ func (c *Client) Username() string { return c.username }
 
 func (c *Client) IP() string { return c.ip }
 
+// IPInfo는 접속할 때 GeoIP로 찾은 정보입니다. 찾지 못했으면 nil입니다.
+func (c *Client) IPInfo() *IpInfo { return c.ipInfo }
+
+func (c *Client) Room() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+func (c *Client) Peer() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.peer
+}
+
+func (c *Client) setTheme(name string) {
+	c.mu.Lock()
+	c.theme = lookupTheme(name)
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+func (c *Client) themeName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.theme.Name
+}
+
+// updateClockLocked는 고른 시간대나 GeoIP 시간대로 clock을 다시 만듭니다. c.mu를 잡은 상태에서 호출해야 합니다.
+func (c *Client) updateClockLocked() {
+	zone, _ := c.timezoneLocked()
+	c.clock = newClock(zone, c.timeFormat)
+}
+
+// timezoneLocked는 현재 시간대 이름과, 그 시간대가 GeoIP에서 왔는지를 반환합니다.
+func (c *Client) timezoneLocked() (string, bool) {
+	if c.zone != "" {
+		return c.zone, false
+	}
+	if c.ipInfo != nil {
+		if _, err := loadZone(c.ipInfo.Timezone); err == nil {
+			return c.ipInfo.Timezone, true
+		}
+	}
+	return time.Local.String(), true
+}
+
+func (c *Client) timezone() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timezoneLocked()
+}
+
+func (c *Client) setTimezone(zone string) {
+	c.mu.Lock()
+	c.zone = zone
+	c.updateClockLocked()
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+func (c *Client) setTimeFormat(format string) {
+	c.mu.Lock()
+	c.timeFormat = format
+	c.updateClockLocked()
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+// messageWidthLocked는 사이드바를 뺀 메시지 영역의 너비입니다. c.mu를 잡은 상태에서 호출해야 합니다.
+func (c *Client) messageWidthLocked() int {
+	if c.sidebar && c.width >= minSidebarTermWidth {
+		return c.width - sidebarWidth
+	}
+	return c.width
+}
+
+func (c *Client) sidebarShown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sidebar
+}
+
+func (c *Client) setSidebar(show bool) {
+	c.mu.Lock()
+	c.sidebar = show
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+// Away는 /away 메시지를 반환합니다. 자리에 있으면 빈 문자열입니다.
+func (c *Client) Away() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.away
+}
+
+func (c *Client) setAway(message string) {
+	c.mu.Lock()
+	c.away = message
+	c.mu.Unlock()
+
+	c.TrySendRender()
+}
+
+// Role은 이 세션 사용자의 역할입니다.
+func (c *Client) Role() Role {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.role
+}
+
+func (c *Client) setRole(role Role) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.role = role
+}
+
+// Fingerprint는 접속에 사용한 공개키의 SHA256 지문입니다.
+func (c *Client) Fingerprint() string {
+	if c.session == nil || c.session.PublicKey() == nil {
+		return ""
+	}
+	return gossh.FingerprintSHA256(c.session.PublicKey())
+}
+
+// Disconnect는 reason을 남기고 세션을 끊습니다. reason은 터미널을 복구한 뒤에 출력됩니다.
+func (c *Client) Disconnect(reason string) {
+	c.mu.Lock()
+	c.closeReason = strings.TrimSpace(reason)
+	c.mu.Unlock()
+
+	c.emitClose()
+}
+
+// Idle은 마지막으로 키를 입력한 뒤 지난 시간입니다.
+func (c *Client) Idle() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Since(c.lastInput)
+}
+
+func (c *Client) setNotify(mode string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = mode
+}
+
+func (c *Client) timeFormatName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timeFormat
+}
+
 func (c *Client) Size() (int, int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -333,6 +1004,14 @@ func (c *Client) emitClose() {
 	c.once.Do(func() {
 		// **[추가]** SSH 세션 자체를 닫습니다.
 		if c.session != nil {
+			c.screen.restore(c.session)
+
+			c.mu.Lock()
+			reason := c.closeReason
+			c.mu.Unlock()
+			if reason != "" {
+				_, _ = fmt.Fprintf(c.session, "[system] %s\r\n", reason)
+			}
 			_ = c.session.Close() // 오류 처리는 간단히 무시합니다.
 		}
 
@@ -363,13 +1042,12 @@ func (c *Client) TrySendRender() {
 		return
 	}
 
+	// 콜백이 잠금을 기다리는 동안 Reset되면 같은 콜백이 한 번 더 실행될 수 있으므로 타이머를 다시 만지지 않습니다.
 	c.renderDebounceTimer = time.AfterFunc(c.renderDebounceDur, func() {
 		c.mu.Lock()
 		defer c.mu.Unlock()
 
 		c.trySend(c.RenderCh)
-
-		c.renderDebounceTimer.Stop()
 		c.renderDebounceTimer = nil
 	})
 }
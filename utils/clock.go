@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // 서버에 zoneinfo가 없어도 /tz로 모든 시간대를 고를 수 있도록 포함합니다.
+
+	"sshchat/db"
+)
+
+// DefaultTimeFormat은 프로필에 시간 형식이 없을 때 사용하는 형식입니다.
+const DefaultTimeFormat = "full"
+
+// timeFormats는 /timefmt로 고를 수 있는 메시지 시간 형식입니다.
+var timeFormats = map[string]string{
+	"compact": "15:04",
+	"short":   "15:04:05",
+	"full":    "2006-01-02 15:04:05",
+}
+
+// TimeFormatNames는 선택할 수 있는 시간 형식 이름을 정렬해서 반환합니다.
+func TimeFormatNames() []string {
+	names := make([]string, 0, len(timeFormats))
+	for name := range timeFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// clock은 메시지 시간을 보는 사람의 시간대와 형식으로 바꿉니다.
+type clock struct {
+	loc    *time.Location
+	layout string
+}
+
+// newClock은 시간대 이름과 형식 이름으로 clock을 만듭니다.
+// 시간대를 찾을 수 없으면 서버 시간대를, 형식을 모르면 기본 형식을 사용합니다.
+func newClock(zone string, format string) clock {
+	loc, err := loadZone(zone)
+	if err != nil {
+		loc = time.Local
+	}
+	layout, ok := timeFormats[format]
+	if !ok {
+		layout = timeFormats[DefaultTimeFormat]
+	}
+
+	return clock{loc: loc, layout: layout}
+}
+
+func (ck clock) format(t time.Time) string {
+	if ck.loc == nil || ck.layout == "" {
+		return t.Format(timeFormats[DefaultTimeFormat])
+	}
+	return t.In(ck.loc).Format(ck.layout)
+}
+
+// loadZone은 "Asia/Seoul" 같은 IANA 시간대 이름을 불러옵니다.
+// 빈 문자열과 "Local"은 보는 사람의 시간대가 아니므로 거부합니다.
+func loadZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+
+	return loc, nil
+}
+
+// saveTimezone은 Client의 시간대를 바꾸고 프로필에 저장합니다. 빈 문자열은 GeoIP 시간대로 되돌립니다.
+func (h *Hub) saveTimezone(c *Client, zone string) error {
+	err := h.updateProfile(c, "timezone", func(ctx context.Context) error {
+		return db.SetUserTimezone(ctx, h.db, c.Username(), zone)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.setTimezone(zone)
+	return nil
+}
+
+// saveTimeFormat은 Client의 시간 형식을 바꾸고 프로필에 저장합니다.
+func (h *Hub) saveTimeFormat(c *Client, format string) error {
+	err := h.updateProfile(c, "time format", func(ctx context.Context) error {
+		return db.SetUserTimeFormat(ctx, h.db, c.Username(), format)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.setTimeFormat(format)
+	return nil
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "tz",
+		Usage: "/tz [zone|auto]",
+		Help:  "show or change your timezone, e.g. /tz Asia/Seoul",
+		Run: func(c *Client, args []string) error {
+			switch len(args) {
+			case 0:
+				zone, auto := c.timezone()
+				if auto {
+					c.systemMessage("timezone: %s (from your location, change with /tz <zone>)", zone)
+				} else {
+					c.systemMessage("timezone: %s (/tz auto to use your location)", zone)
+				}
+				return nil
+			case 1:
+			default:
+				return usageError(commands["tz"])
+			}
+
+			zone := args[0]
+			if strings.EqualFold(zone, "auto") {
+				zone = ""
+			} else if _, err := loadZone(zone); err != nil {
+				return err
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			if err := hub.saveTimezone(c, zone); err != nil {
+				return err
+			}
+
+			current, _ := c.timezone()
+			c.systemMessage("timezone set to %s", current)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:  "timefmt",
+		Usage: "/timefmt [" + strings.Join(TimeFormatNames(), "|") + "]",
+		Help:  "show or change how message times are shown",
+		Run: func(c *Client, args []string) error {
+			switch len(args) {
+			case 0:
+				c.systemMessage("time format: %s (available: %s)", c.timeFormatName(), strings.Join(TimeFormatNames(), ", "))
+				return nil
+			case 1:
+			default:
+				return usageError(commands["timefmt"])
+			}
+
+			format := strings.ToLower(args[0])
+			if _, ok := timeFormats[format]; !ok {
+				return fmt.Errorf("unknown time format %q: use %s", args[0], strings.Join(TimeFormatNames(), ", "))
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			if err := hub.saveTimeFormat(c, format); err != nil {
+				return err
+			}
+
+			c.systemMessage("time format set to %s", format)
+			return nil
+		},
+	})
+}
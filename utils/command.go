@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anmitsu/go-shlex"
+)
+
+// Command는 입력 줄에서 '/'로 시작하는 명령 하나를 정의합니다.
+type Command struct {
+	Name  string
+	Usage string
+	Help  string
+
+	// MaxArgs가 0보다 크면 인자를 최대 MaxArgs개로 나누고, 마지막 인자에 나머지 문자열을
+	// 그대로 담습니다. 메시지 본문처럼 따옴표를 해석하면 안 되는 인자에 사용합니다.
+	MaxArgs int
+
+	// Background가 true이면 입력을 처리하는 goroutine과 따로 실행합니다.
+	// DB를 조회하는 명령이 DB가 느릴 때 입력과 화면을 멈추지 않게 합니다.
+	Background bool
+
+	Run func(c *Client, args []string) error
+}
+
+var commands = make(map[string]*Command)
+
+// RegisterCommand adds cmd to the command table. It panics on duplicate names
+// so that conflicting registrations are caught at startup.
+func RegisterCommand(cmd *Command) {
+	if _, ok := commands[cmd.Name]; ok {
+		panic(fmt.Sprintf("command /%s is already registered", cmd.Name))
+	}
+	commands[cmd.Name] = cmd
+}
+
+// CommandNames는 등록된 명령 이름을 정렬해서 반환합니다.
+func CommandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// usageError는 명령 사용법이 틀렸음을 나타냅니다.
+func usageError(cmd *Command) error {
+	return fmt.Errorf("usage: %s", cmd.Usage)
+}
+
+// parseCommandLine은 "/name args..." 형태의 줄을 명령 이름과 인자로 나눕니다.
+func parseCommandLine(line string) (string, string) {
+	line = strings.TrimPrefix(line, "/")
+	name, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+
+	return strings.ToLower(name), strings.TrimSpace(rest)
+}
+
+// splitArgs는 명령 설정에 따라 인자 문자열을 나눕니다.
+func splitArgs(cmd *Command, rest string) ([]string, error) {
+	if rest == "" {
+		return []string{}, nil
+	}
+
+	if cmd.MaxArgs > 0 {
+		args := make([]string, 0, cmd.MaxArgs)
+		for len(args) < cmd.MaxArgs-1 {
+			field, remain, found := strings.Cut(rest, " ")
+			args = append(args, field)
+			rest = strings.TrimSpace(remain)
+			if !found || rest == "" {
+				return args, nil
+			}
+		}
+		return append(args, rest), nil
+	}
+
+	args, err := shlex.Split(rest, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %v", err)
+	}
+
+	return args, nil
+}
+
+// handleCommand는 '/'로 시작하는 입력 줄을 파싱해 등록된 명령으로 전달합니다.
+// 오류는 이 Client 화면에 [system] 메시지로 표시됩니다.
+func (c *Client) handleCommand(line string) {
+	name, rest := parseCommandLine(line)
+	if name == "" {
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		c.systemMessage("unknown command: /%s (see /help)", name)
+		return
+	}
+
+	args, err := splitArgs(cmd, rest)
+	if err != nil {
+		c.systemMessage("/%s: %v", name, err)
+		return
+	}
+
+	run := func() {
+		if err := cmd.Run(c, args); err != nil {
+			c.systemMessage("/%s: %v", name, err)
+		}
+	}
+	if cmd.Background {
+		go run()
+		return
+	}
+	run()
+}
+
+// requireHub는 허브에 등록된 Client의 허브를 반환합니다.
+func (c *Client) requireHub() (*Hub, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.hub == nil {
+		return nil, fmt.Errorf("not connected to a hub")
+	}
+
+	return c.hub, nil
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "help",
+		Usage: "/help [command]",
+		Help:  "list commands or show help for one command",
+		Run: func(c *Client, args []string) error {
+			if len(args) > 0 {
+				cmd, ok := commands[strings.ToLower(strings.TrimPrefix(args[0], "/"))]
+				if !ok {
+					return fmt.Errorf("unknown command: %s", args[0])
+				}
+				c.systemMessage("%s - %s", cmd.Usage, cmd.Help)
+				return nil
+			}
+
+			for _, name := range CommandNames() {
+				cmd := commands[name]
+				c.systemMessage("%s - %s", cmd.Usage, cmd.Help)
+			}
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:  "join",
+		Usage: "/join #room",
+		Help:  "join a room, creating it if needed",
+		Run: func(c *Client, args []string) error {
+			if len(args) != 1 {
+				return usageError(commands["join"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			name, err := NormalizeRoomName(args[0])
+			if err != nil {
+				return err
+			}
+			if name == c.Room() {
+				return fmt.Errorf("already in #%s", name)
+			}
+
+			if err := hub.Join(c, name); err != nil {
+				return err
+			}
+			c.systemMessage("joined #%s", name)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:  "part",
+		Usage: "/part",
+		Help:  "leave the current room and go back to #" + DefaultRoom,
+		Run: func(c *Client, args []string) error {
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			current := c.Room()
+			if current == DefaultRoom {
+				return fmt.Errorf("cannot leave #%s", DefaultRoom)
+			}
+
+			if err := hub.Join(c, DefaultRoom); err != nil {
+				return err
+			}
+			c.systemMessage("left #%s", current)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:  "rooms",
+		Usage: "/rooms",
+		Help:  "list open rooms with member counts",
+		Run: func(c *Client, args []string) error {
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			for _, room := range hub.Rooms() {
+				c.systemMessage("#%s (%d)", room.Name, room.Members)
+			}
+			return nil
+		},
+	})
+}
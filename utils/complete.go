@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"strings"
+)
+
+// completionCandidates는 커서 앞의 단어 word로 Tab 완성 후보를 만듭니다.
+// 줄 맨 앞의 "/..."는 명령, "#..."는 대화방, 그 밖에는 현재 대화방에 있는 사용자 이름을 완성합니다.
+// 줄 맨 앞에서 이름을 완성하면 "name: "처럼 상대를 부르는 형태로 넣습니다.
+func completionCandidates(hub *Hub, room string, self string, start int, word string) []string {
+	options := make([]string, 0)
+	switch {
+	case start == 0 && strings.HasPrefix(word, "/"):
+		for _, name := range CommandNames() {
+			options = append(options, "/"+name+" ")
+		}
+	case strings.HasPrefix(word, "#"):
+		if hub != nil {
+			for _, info := range hub.Rooms() {
+				options = append(options, "#"+info.Name+" ")
+			}
+		}
+	case word == "":
+		// 빈 자리에서 누른 Tab은 모든 사용자를 후보로 내놓지 않습니다.
+	default:
+		if hub == nil {
+			break
+		}
+		prefix, suffix := "", " "
+		if strings.HasPrefix(word, "@") {
+			prefix = "@"
+		} else if start == 0 {
+			suffix = ": "
+		}
+		for _, name := range hub.Members(room) {
+			if name != self {
+				options = append(options, prefix+name+suffix)
+			}
+		}
+	}
+
+	candidates := make([]string, 0, len(options))
+	for _, option := range options {
+		if len(option) > len(word) && strings.HasPrefix(strings.ToLower(option), strings.ToLower(word)) {
+			candidates = append(candidates, option)
+		}
+	}
+
+	return candidates
+}
+
+// complete는 Tab 키를 처리합니다. 연속으로 누르면 다음 후보로 바꿉니다.
+func (c *Client) complete() {
+	c.mu.Lock()
+	if c.input.CycleCompletion() {
+		c.mu.Unlock()
+		c.TrySendRender()
+		return
+	}
+	start, word := c.input.WordBeforeCursor()
+	hub, room := c.hub, c.room
+	c.mu.Unlock()
+
+	// 허브를 조회하는 동안 c.mu를 잡고 있지 않습니다. 입력 버퍼는 입력 고루틴에서만 바뀝니다.
+	candidates := completionCandidates(hub, room, c.username, start, word)
+
+	c.mu.Lock()
+	changed := c.input.StartCompletion(start, candidates)
+	c.mu.Unlock()
+
+	if changed {
+		c.TrySendRender()
+	}
+}
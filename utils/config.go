@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"log"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,10 +15,37 @@ type Config struct {
 	Port             string
 	Geoip            string
 	CountryBlacklist []string
+	CountryAllowlist []string // 비어있지 않으면 목록에 있는 국가만 접속할 수 있습니다.
 	PgDsn            string
 	RootPath         string
 	LokiHost         string
 	Identify         string
+	HistorySize      int
+	Owners           []string // DB에 저장된 역할과 관계없이 항상 owner 권한을 갖는 키 지문 (SHA256:...)
+
+	// 도배 방지: 사용자와 IP마다 초당 Rate개씩 채워지고 최대 Burst개까지 쌓이는 토큰 버킷
+	UserRate  float64 // 0이면 사용자 제한을 끕니다.
+	UserBurst int
+	IPRate    float64 // 0이면 IP 제한을 끕니다.
+	IPBurst   int
+
+	// 제한을 넘으면 FloodWarnings번 경고한 뒤 FloodMute 동안 음소거하고,
+	// 음소거를 FloodMaxMutes번 받은 뒤에도 계속하면 접속을 끊습니다.
+	FloodWarnings int
+	FloodMute     time.Duration
+	FloodMaxMutes int
+
+	// 익명 IP(VPN, 프록시, Tor)에 대한 정책. 방별로 덮어쓸 수 있습니다.
+	AnonymousPolicy     AccessPolicy
+	RoomAnonymousPolicy map[string]AccessPolicy
+
+	// 방별 국가 제한. 서버 전체 제한을 통과한 사용자에게 추가로 적용됩니다.
+	RoomCountryRules map[string]CountryRule
+
+	// GeoIP보다 먼저 확인하는 네트워크 목록. TrustedNetworks와 루프백은 국가와 익명 IP 제한을 받지 않습니다.
+	IPAllowlist     []netip.Prefix // 비어있지 않으면 목록에 있는 네트워크만 접속할 수 있습니다.
+	IPDenylist      []netip.Prefix
+	TrustedNetworks []netip.Prefix
 }
 
 func GetConfig() *Config {
@@ -27,14 +58,108 @@ func GetConfig() *Config {
 	rootPath := os.Getenv("ROOT_PATH")
 	lokiHost := os.Getenv("LOKI_HOST")
 	identify := os.Getenv("IDENTIFY")
+	historySize, err := strconv.Atoi(os.Getenv("HISTORY_SIZE"))
+	if err != nil || historySize <= 0 {
+		historySize = 100
+	}
+	owners := ownerFingerprints(os.Getenv("OWNERS"))
+	anonymousPolicy := envAccessPolicy("ANONYMOUS_POLICY", PolicyAllow)
 
 	return &Config{
 		Port:             port,
 		Geoip:            geoipDbfile,
-		CountryBlacklist: strings.Split(countryBlacklist, ","),
+		CountryBlacklist: countryList(countryBlacklist, ","),
+		CountryAllowlist: countryList(os.Getenv("COUNTRY_ALLOWLIST"), ","),
 		PgDsn:            pgDsn,
 		RootPath:         rootPath,
 		LokiHost:         lokiHost,
 		Identify:         identify,
+		HistorySize:      historySize,
+		Owners:           owners,
+
+		UserRate:  envFloat("RATE_LIMIT_USER_RATE", 1),
+		UserBurst: envBurst("RATE_LIMIT_USER_BURST", 5),
+		IPRate:    envFloat("RATE_LIMIT_IP_RATE", 2),
+		IPBurst:   envBurst("RATE_LIMIT_IP_BURST", 10),
+
+		FloodWarnings: envInt("FLOOD_WARNINGS", 3),
+		FloodMute:     envDuration("FLOOD_MUTE", time.Minute),
+		FloodMaxMutes: envInt("FLOOD_MAX_MUTES", 2),
+
+		AnonymousPolicy:     anonymousPolicy,
+		RoomAnonymousPolicy: envRoomPolicies("ROOM_ANONYMOUS_POLICY", anonymousPolicy),
+
+		RoomCountryRules: roomCountryRules(
+			envRoomCountries("ROOM_COUNTRY_ALLOWLIST"),
+			envRoomCountries("ROOM_COUNTRY_BLACKLIST"),
+		),
+
+		IPAllowlist:     envPrefixes("IP_ALLOWLIST"),
+		IPDenylist:      envPrefixes("IP_DENYLIST"),
+		TrustedNetworks: envPrefixes("TRUSTED_NETWORKS"),
+	}
+}
+
+// envInt는 환경 변수를 정수로 읽습니다. 없거나 음수이면 def를 반환합니다.
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || v < 0 {
+		return def
 	}
+	return v
+}
+
+// envBurst는 토큰 버킷의 크기를 읽습니다. 0이면 모든 메시지가 막히므로 경고를 남기고 def를 사용합니다.
+// 제한을 끄려면 RATE_LIMIT_*_RATE를 0으로 설정합니다.
+func envBurst(name string, def int) int {
+	v := envInt(name, def)
+	if v == 0 {
+		log.Printf("%s: must be at least 1 (set the rate to 0 to disable the limit), using %d", name, def)
+		return def
+	}
+	return v
+}
+
+// envFloat는 환경 변수를 실수로 읽습니다. 없거나 음수이면 def를 반환합니다.
+func envFloat(name string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
+
+// envDuration은 "30s", "5m" 같은 환경 변수를 읽습니다. 없거나 잘못된 값이면 def를 반환합니다.
+func envDuration(name string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+// splitList는 쉼표로 구분된 값을 나눕니다. 앞뒤 공백과 빈 항목은 버립니다.
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// ownerFingerprints는 OWNERS에서 키 지문만 골라냅니다. 사용자 이름은 누구나 먼저 차지할 수 있으므로 받지 않습니다.
+func ownerFingerprints(s string) []string {
+	owners := make([]string, 0)
+	for _, item := range splitList(s) {
+		if !strings.HasPrefix(item, "SHA256:") {
+			log.Printf("OWNERS: %q is not a key fingerprint (SHA256:...), ignored", item)
+			continue
+		}
+		owners = append(owners, item)
+	}
+
+	return owners
 }
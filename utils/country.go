@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CountryRule은 접속 국가 제한입니다. Allow가 비어있지 않으면 목록에 있는 국가만 받고,
+// Deny에 있는 국가는 항상 막습니다.
+type CountryRule struct {
+	Allow []string
+	Deny  []string
+}
+
+// Check는 country(ISO 3166-1 alpha-2)가 규칙에 걸리면 이유를 담은 오류를 반환합니다.
+func (r CountryRule) Check(country string) error {
+	if slices.Contains(r.Deny, country) {
+		return fmt.Errorf("country %s is blacklisted", country)
+	}
+	if len(r.Allow) > 0 && !slices.Contains(r.Allow, country) {
+		return fmt.Errorf("country %s is not in the allowlist", country)
+	}
+	return nil
+}
+
+// CountryRuleFor는 서버 전체 규칙입니다. room이 주어지면 방별 규칙을 반환합니다. 방별 규칙이 없으면 빈 규칙입니다.
+func (cfg *Config) CountryRuleFor(room string) CountryRule {
+	if room == "" {
+		return CountryRule{Allow: cfg.CountryAllowlist, Deny: cfg.CountryBlacklist}
+	}
+	return cfg.RoomCountryRules[room]
+}
+
+// countryList는 "KR, jp" 같은 국가 코드 목록을 대문자로 나눕니다.
+func countryList(s string, sep string) []string {
+	countries := make([]string, 0)
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.ToUpper(strings.TrimSpace(item)); item != "" {
+			countries = append(countries, item)
+		}
+	}
+
+	return countries
+}
+
+// envRoomCountries는 "room=KR|JP,room=US" 형태의 환경 변수를 방별 국가 목록으로 읽습니다.
+// 잘못된 항목은 경고를 남기고 건너뜁니다.
+func envRoomCountries(name string) map[string][]string {
+	rooms := make(map[string][]string)
+	for _, item := range splitList(os.Getenv(name)) {
+		roomName, countries, ok := strings.Cut(item, "=")
+		room, err := NormalizeRoomName(roomName)
+		if !ok || err != nil {
+			log.Printf("%s: invalid entry %q, expected room=CC|CC", name, item)
+			continue
+		}
+		rooms[room] = append(rooms[room], countryList(countries, "|")...)
+	}
+
+	return rooms
+}
+
+// roomCountryRules는 방별 허용 목록과 차단 목록을 하나의 규칙으로 합칩니다.
+func roomCountryRules(allow, deny map[string][]string) map[string]CountryRule {
+	rules := make(map[string]CountryRule)
+	for room, countries := range allow {
+		rule := rules[room]
+		rule.Allow = countries
+		rules[room] = rule
+	}
+	for room, countries := range deny {
+		rule := rules[room]
+		rule.Deny = countries
+		rules[room] = rule
+	}
+
+	return rules
+}
+
+// checkCountry는 room의 국가 규칙이 c의 접속 국가를 막으면 입장을 거부합니다.
+func (h *Hub) checkCountry(c *Client, room string) error {
+	info := c.IPInfo()
+	if info == nil || info.Trusted || h.config == nil {
+		return nil
+	}
+
+	if err := h.config.CountryRuleFor(room).Check(info.Country); err != nil {
+		h.logger.Info("[sshchat] country rejected", "user", c.Username(), "remote", c.IP(), "room", room, "country", info.Country, "reason", err)
+		return fmt.Errorf("#%s does not allow your access country: %v", room, err)
+	}
+	return nil
+}
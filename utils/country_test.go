@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountryRuleCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    CountryRule
+		country string
+		wantErr bool
+	}{
+		{name: "empty rule allows all", country: "US"},
+		{name: "blacklisted", rule: CountryRule{Deny: []string{"CN"}}, country: "CN", wantErr: true},
+		{name: "not blacklisted", rule: CountryRule{Deny: []string{"CN"}}, country: "KR"},
+		{name: "allowlist admits member", rule: CountryRule{Allow: []string{"KR", "JP"}}, country: "JP"},
+		{name: "allowlist rejects others", rule: CountryRule{Allow: []string{"KR", "JP"}}, country: "US", wantErr: true},
+		{name: "allowlist rejects unknown", rule: CountryRule{Allow: []string{"KR"}}, country: "ZZ", wantErr: true},
+		{name: "deny beats allow", rule: CountryRule{Allow: []string{"KR", "JP"}, Deny: []string{"JP"}}, country: "JP", wantErr: true},
+		{name: "empty blacklist entry does not match", rule: CountryRule{Deny: countryList("", ",")}, country: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Check(tt.country); (err != nil) != tt.wantErr {
+				t.Fatalf("Check(%q) error = %v, wantErr %v", tt.country, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCountryList(t *testing.T) {
+	tests := []struct {
+		in   string
+		sep  string
+		want []string
+	}{
+		{in: "", sep: ",", want: []string{}},
+		{in: " , ,", sep: ",", want: []string{}},
+		{in: "kr, Jp ,US", sep: ",", want: []string{"KR", "JP", "US"}},
+		{in: "kr|jp|", sep: "|", want: []string{"KR", "JP"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := countryList(tt.in, tt.sep); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("countryList(%q, %q) = %q, want %q", tt.in, tt.sep, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvRoomCountries(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want map[string][]string
+	}{
+		{name: "empty", env: "", want: map[string][]string{}},
+		{name: "single room", env: "internal=KR|JP", want: map[string][]string{"internal": {"KR", "JP"}}},
+		{
+			name: "normalizes room and case",
+			env:  " #Internal = kr | jp , ops=us",
+			want: map[string][]string{"internal": {"KR", "JP"}, "ops": {"US"}},
+		},
+		{name: "repeated room merges", env: "ops=KR,ops=JP", want: map[string][]string{"ops": {"KR", "JP"}}},
+		{name: "invalid entries skipped", env: "noequals,bad room=KR,ok=JP", want: map[string][]string{"ok": {"JP"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_ROOM_COUNTRIES", tt.env)
+			if got := envRoomCountries("TEST_ROOM_COUNTRIES"); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("envRoomCountries(%q) = %v, want %v", tt.env, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountryRuleFor(t *testing.T) {
+	cfg := &Config{
+		CountryAllowlist: []string{"KR", "JP"},
+		CountryBlacklist: []string{"CN"},
+		RoomCountryRules: roomCountryRules(
+			map[string][]string{"internal": {"KR"}},
+			map[string][]string{"internal": {"JP"}, "public": {"RU"}},
+		),
+	}
+
+	tests := []struct {
+		room    string
+		country string
+		wantErr bool
+	}{
+		{room: "", country: "KR"},
+		{room: "", country: "US", wantErr: true},
+		{room: "", country: "CN", wantErr: true},
+		{room: "internal", country: "KR"},
+		{room: "internal", country: "JP", wantErr: true},
+		{room: "public", country: "US"},
+		{room: "public", country: "RU", wantErr: true},
+		{room: "lobby", country: "US"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.room+"/"+tt.country, func(t *testing.T) {
+			if err := cfg.CountryRuleFor(tt.room).Check(tt.country); (err != nil) != tt.wantErr {
+				t.Fatalf("CountryRuleFor(%q).Check(%q) error = %v, wantErr %v", tt.room, tt.country, err, tt.wantErr)
+			}
+		})
+	}
+}
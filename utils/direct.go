@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sshchat/db"
+)
+
+// sessionsOfLocked는 username으로 접속한 모든 Client를 반환합니다. h.mu를 잡은 상태에서 호출해야 합니다.
+func (h *Hub) sessionsOfLocked(username string) []*Client {
+	sessions := make([]*Client, 0)
+	for c := range h.clients {
+		if c.Username() == username {
+			sessions = append(sessions, c)
+		}
+	}
+
+	return sessions
+}
+
+// SendDirect는 개인 메시지를 보낸 사람과 받는 사람의 모든 세션에 전달한 뒤 저장합니다.
+// 받는 사람이 접속해 있지 않아도 등록된 사용자라면 저장해 두었다가 다음 접속 때 볼 수 있습니다.
+// 등록 여부를 DB에서 확인해야 할 때는 입력이 멈추지 않도록 따로 확인하고, 그 결과의 오류는 from에게 알립니다.
+func (h *Hub) SendDirect(from *Client, msg Message) error {
+	if msg.Recipient == msg.Username {
+		return fmt.Errorf("cannot send a message to yourself")
+	}
+	if err := h.checkMuted(msg.Username); err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	online := len(h.sessionsOfLocked(msg.Recipient)) > 0
+	h.mu.RUnlock()
+	if online {
+		h.deliverDirect(msg)
+		return nil
+	}
+	if h.db == nil {
+		return fmt.Errorf("%s is not online", msg.Recipient)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		user, err := db.GetUser(ctx, h.db, msg.Recipient)
+		if err != nil {
+			h.logger.Error("[sshchat] failed to look up recipient", "user", msg.Recipient, "error", err)
+			from.systemMessage("failed to send message to %s", msg.Recipient)
+			return
+		}
+		if user == nil {
+			from.systemMessage("no such user: %s", msg.Recipient)
+			return
+		}
+		h.deliverDirect(msg)
+	}()
+	return nil
+}
+
+// deliverDirect는 개인 메시지에 순번을 붙여 양쪽 세션에 전달하고, 저장은 쓰기 대기열에 넣습니다.
+func (h *Hub) deliverDirect(msg Message) {
+	h.mu.Lock()
+	h.seq++
+	msg.seq = h.seq
+	sessions := append(h.sessionsOfLocked(msg.Recipient), h.sessionsOfLocked(msg.Username)...)
+	h.mu.Unlock()
+
+	for _, c := range sessions {
+		c.receive(msg)
+	}
+
+	// 대기열이 가득 차면 저장만 건너뜁니다.
+	_ = h.enqueue(func() {
+		if id := h.persistDirect(msg); id > 0 {
+			h.assignID(msg, id)
+		}
+	})
+}
+
+// persistDirect는 개인 메시지를 저장하고 ID를 반환합니다. 실패하면 로그만 남기고 0을 반환합니다.
+func (h *Hub) persistDirect(msg Message) int64 {
+	if h.db == nil {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := &db.DirectMessage{
+		Sender:    msg.Username,
+		Recipient: msg.Recipient,
+		Timestamp: msg.Timestamp,
+		Content:   msg.Content,
+	}
+	if err := db.InsertDirectMessage(ctx, h.db, row); err != nil {
+		h.logger.Error("[sshchat] failed to persist direct message", "user", msg.Username, "recipient", msg.Recipient, "error", err)
+		return 0
+	}
+
+	return row.ID
+}
+
+func directMessageFromRow(row db.DirectMessage) Message {
+	return Message{
+		ID:        row.ID,
+		Timestamp: row.Timestamp,
+		Username:  row.Sender,
+		Content:   row.Content,
+		Recipient: row.Recipient,
+	}
+}
+
+// OpenDirect는 Client 화면을 peer와의 개인 대화로 전환하고 저장된 대화 내용을 불러옵니다.
+// 화면을 먼저 바꾼 뒤 따로 불러오므로 그 사이 도착한 메시지도 남고, DB가 느려도 입력이 멈추지 않습니다.
+func (h *Hub) OpenDirect(c *Client, peer string) {
+	c.mu.Lock()
+	c.peer = peer
+	c.resetViewLocked(make([]Message, 0))
+	c.mu.Unlock()
+
+	c.TrySendRender()
+	go func() {
+		h.loadDirectHistory(c, peer)
+		c.TrySendRender()
+	}()
+}
+
+// loadDirectHistory는 peer와 주고받은 최근 개인 메시지를 DB에서 읽어 c의 화면에 합칩니다.
+func (h *Hub) loadDirectHistory(c *Client, peer string) {
+	if h.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := db.GetDirectMessages(ctx, h.db, c.Username(), peer, 0, h.historySize)
+	if err != nil {
+		h.logger.Error("[sshchat] failed to load direct messages", "user", c.Username(), "peer", peer, "error", err)
+		return
+	}
+	history := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		history = append(history, directMessageFromRow(row))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.peer != peer {
+		return
+	}
+	c.messages = mergeHistory(history, c.messages)
+}
+
+// CloseDirect는 개인 대화 화면을 닫고 현재 대화방의 대화 내용을 다시 불러옵니다.
+func (h *Hub) CloseDirect(c *Client) {
+	h.mu.RLock()
+	c.mu.Lock()
+	name := c.room
+	recent := make([]Message, 0)
+	if room, ok := h.rooms[name]; ok {
+		recent = h.recentLocked(room)
+	}
+	c.peer = ""
+	c.resetViewLocked(recent)
+	c.mu.Unlock()
+	h.mu.RUnlock()
+
+	c.TrySendRender()
+	go func() {
+		h.loadHistory(c, name)
+		c.TrySendRender()
+	}()
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:    "msg",
+		Usage:   "/msg <user> <text>",
+		Help:    "send a private message",
+		MaxArgs: 2,
+		Run: func(c *Client, args []string) error {
+			if len(args) != 2 {
+				return usageError(commands["msg"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			if err := hub.checkPost(c, ""); err != nil {
+				return err
+			}
+
+			return hub.SendDirect(c, Message{
+				Timestamp: time.Now(),
+				Username:  c.Username(),
+				Content:   args[1],
+				Recipient: args[0],
+			})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:  "query",
+		Usage: "/query [user]",
+		Help:  "open a private conversation, or go back to the room without a user",
+		Run: func(c *Client, args []string) error {
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			switch len(args) {
+			case 0:
+				if c.Peer() == "" {
+					return fmt.Errorf("not in a private conversation")
+				}
+				hub.CloseDirect(c)
+				c.systemMessage("back to #%s", c.Room())
+			case 1:
+				if args[0] == c.Username() {
+					return fmt.Errorf("cannot talk to yourself")
+				}
+				hub.OpenDirect(c, args[0])
+				c.systemMessage("private conversation with %s (/query to leave)", args[0])
+			default:
+				return usageError(commands["query"])
+			}
+			return nil
+		},
+	})
+}
@@ -13,6 +13,10 @@ type IpInfo struct {
 	Timezone      string
 	Isp           string
 	IsAnonymousIP bool
+	// AnonymousKinds는 익명 IP로 판단한 근거입니다. (vpn, proxy, residential-proxy, tor, anonymous)
+	AnonymousKinds []string
+	// Trusted는 루프백이나 TRUSTED_NETWORKS에서 접속해 국가와 익명 IP 제한을 받지 않는다는 뜻입니다.
+	Trusted bool
 }
 
 func GetDB(db string) (*geoip2.Reader, error) {
@@ -54,16 +58,29 @@ func GetIPInfo(ip string, db *geoip2.Reader) *IpInfo {
 			return "Unknown"
 		}
 	}(parsedIp)
-	isAnonymousIP := func(ip net.IP) bool {
+	anonymousKinds := func(ip net.IP) []string {
+		kinds := make([]string, 0)
 		is, _ := db.AnonymousIP(parsedIp)
+		if is == nil {
+			return kinds
+		}
 
-		if is != nil {
-			return is.IsAnonymousVPN ||
-				is.IsPublicProxy ||
-				is.IsAnonymous
-		} else {
-			return false
+		if is.IsAnonymousVPN {
+			kinds = append(kinds, "vpn")
+		}
+		if is.IsPublicProxy {
+			kinds = append(kinds, "proxy")
+		}
+		if is.IsResidentialProxy {
+			kinds = append(kinds, "residential-proxy")
 		}
+		if is.IsTorExitNode {
+			kinds = append(kinds, "tor")
+		}
+		if is.IsAnonymous && len(kinds) == 0 {
+			kinds = append(kinds, "anonymous")
+		}
+		return kinds
 	}(parsedIp)
 
 	return &IpInfo{
@@ -71,6 +88,8 @@ func GetIPInfo(ip string, db *geoip2.Reader) *IpInfo {
 		City:          city,
 		Timezone:      timezone,
 		Isp:           isp,
-		IsAnonymousIP: isAnonymousIP,
+		IsAnonymousIP: len(anonymousKinds) > 0,
+
+		AnonymousKinds: anonymousKinds,
 	}
 }
@@ -0,0 +1,524 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sshchat/db"
+
+	"github.com/uptrace/bun"
+)
+
+// DefaultRoom은 접속 직후 들어가게 되는 기본 대화방 이름입니다.
+const DefaultRoom = "lobby"
+
+// writeQueueSize는 DB에 쓰기를 기다릴 수 있는 작업 수입니다. 가득 차면 새 메시지를 거절합니다.
+const writeQueueSize = 256
+
+var roomNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// usernameRe는 사용할 수 있는 사용자 이름입니다. 제어 문자, 공백, '@', ':' 등은 화면 출력과 명령 인자,
+// 멘션, /ban 대상 구분을 깨뜨리므로 허용하지 않습니다.
+var usernameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+
+// Room은 같은 대화를 공유하는 Client 묶음입니다.
+type Room struct {
+	Name     string
+	clients  map[*Client]struct{}
+	messages []Message
+}
+
+// RoomInfo는 /rooms 목록에 표시되는 대화방 요약입니다.
+type RoomInfo struct {
+	Name    string
+	Members int
+}
+
+// Hub는 접속한 모든 Client와 대화방을 관리하고 메시지를 팬아웃합니다.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	rooms   map[string]*Room
+	maxKeep int
+	mutes   map[string]time.Time // 음소거된 사용자와 만료 시간 (영구이면 zero)
+	limiter *rateLimiter
+	writes  chan func() // DB에 쓰는 작업을 순서대로 처리하는 대기열
+	seq     uint64      // 마지막으로 전달한 메시지의 순번 (Message.seq)
+
+	db          *bun.DB
+	logger      *slog.Logger
+	config      *Config
+	historySize int
+}
+
+// NewHub creates a Hub with only the default room. Each room keeps its most
+// recent messages in memory and every broadcast message is persisted to pgDb.
+func NewHub(pgDb *bun.DB, logger *slog.Logger, config *Config) *Hub {
+	h := &Hub{
+		clients:     make(map[*Client]struct{}),
+		rooms:       make(map[string]*Room),
+		maxKeep:     maxClientMessages,
+		mutes:       make(map[string]time.Time),
+		limiter:     newRateLimiter(config),
+		writes:      make(chan func(), writeQueueSize),
+		db:          pgDb,
+		logger:      logger,
+		config:      config,
+		historySize: config.HistorySize,
+	}
+	h.rooms[DefaultRoom] = newRoom(DefaultRoom)
+	h.loadMutes()
+	go h.writeLoop()
+
+	return h
+}
+
+func newRoom(name string) *Room {
+	return &Room{
+		Name:     name,
+		clients:  make(map[*Client]struct{}),
+		messages: make([]Message, 0),
+	}
+}
+
+// NormalizeRoomName은 "#Name" 형태의 입력을 방 이름으로 바꾸고 유효성을 검사합니다.
+func NormalizeRoomName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if !roomNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid room name %q: use up to 32 letters, digits, '-' or '_'", name)
+	}
+
+	return name, nil
+}
+
+// ValidateUsername은 SSH 사용자 이름을 대화명으로 쓸 수 있는지 확인합니다.
+// 시스템 메시지와 헷갈리지 않도록 "system"도 쓸 수 없습니다.
+func ValidateUsername(name string) error {
+	if !usernameRe.MatchString(name) {
+		return fmt.Errorf("invalid username %q: use up to 32 letters, digits, '-' or '_'", name)
+	}
+	if strings.EqualFold(name, "system") {
+		return fmt.Errorf("username %q is reserved", name)
+	}
+
+	return nil
+}
+
+// Register는 저장된 설정과 역할을 불러온 뒤 Client를 허브에 등록하고 기본 대화방에 입장시킵니다.
+// 권한 확인은 접속 중인 세션의 역할을 믿으므로, 역할을 불러오지 못하면 등록하지 않고 오류를 반환합니다.
+// 기본 대화방에 들어갈 수 없을 때도 등록을 취소하고 오류를 반환합니다.
+func (h *Hub) Register(c *Client) error {
+	if err := h.loadProfile(c); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	c.mu.Lock()
+	c.hub = h
+	c.mu.Unlock()
+
+	if err := h.Join(c, DefaultRoom); err != nil {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Unregister는 Client를 현재 대화방과 허브에서 제거합니다. 여러 번 호출해도 안전합니다.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	_, registered := h.clients[c]
+	delete(h.clients, c)
+	left := h.leaveLocked(c)
+	h.mu.Unlock()
+
+	if registered && left != "" {
+		h.announce(left, "%s left", c.Username())
+	}
+}
+
+// Join은 Client를 현재 대화방에서 내보내고 name 대화방에 입장시킵니다.
+// 대화방이 없으면 새로 만들고, 해당 방의 최근 대화 내용으로 화면을 교체합니다.
+// 방의 정책상 들어갈 수 없으면 현재 방에 그대로 두고 오류를 반환합니다.
+func (h *Hub) Join(c *Client, name string) error {
+	if err := h.checkCountry(c, name); err != nil {
+		return err
+	}
+	if err := h.checkAnonymousJoin(c, name); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	left := h.leaveLocked(c)
+	room, ok := h.rooms[name]
+	if !ok {
+		room = newRoom(name)
+		h.rooms[name] = room
+	}
+	joined := !h.hasUserLocked(room, c.Username())
+	room.clients[c] = struct{}{}
+
+	// 방에 들어가는 것과 같은 잠금 안에서 화면을 메모리의 최근 메시지로 교체해야
+	// 그 사이 브로드캐스트된 메시지를 놓치거나 두 번 보여주지 않습니다.
+	c.mu.Lock()
+	c.room = name
+	c.peer = ""
+	c.resetViewLocked(h.recentLocked(room))
+	c.mu.Unlock()
+	h.mu.Unlock()
+
+	if left != "" {
+		h.announce(left, "%s left", c.Username())
+	}
+	if joined {
+		h.announce(name, "%s joined", c.Username())
+	}
+
+	c.TrySendRender()
+	go func() {
+		h.loadHistory(c, name)
+		c.TrySendRender()
+	}()
+	return nil
+}
+
+// leaveLocked는 Client를 현재 대화방에서 제거합니다. 비어있는 방은 기본 방을 제외하고 삭제됩니다.
+// 같은 사용자의 다른 세션이 남아있지 않아 퇴장을 알려야 하는 방의 이름을 반환합니다.
+// h.mu를 잡은 상태에서 호출해야 합니다.
+func (h *Hub) leaveLocked(c *Client) string {
+	left := ""
+	for name, room := range h.rooms {
+		if _, ok := room.clients[c]; !ok {
+			continue
+		}
+		delete(room.clients, c)
+		if len(room.clients) == 0 && name != DefaultRoom {
+			delete(h.rooms, name)
+			continue
+		}
+		if !h.hasUserLocked(room, c.Username()) {
+			left = name
+		}
+	}
+
+	return left
+}
+
+// hasUserLocked는 room에 username으로 접속한 세션이 있는지 확인합니다. h.mu를 잡은 상태에서 호출해야 합니다.
+func (h *Hub) hasUserLocked(room *Room, username string) bool {
+	for c := range room.clients {
+		if c.Username() == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Broadcast는 msg.Room에 있는 모든 Client에게 메시지를 전달하고 렌더링을 요청한 뒤 저장합니다.
+// DB가 느리거나 멈춰도 대화가 멈추지 않도록 먼저 전달하고, 저장은 쓰기 대기열에서 따로 합니다.
+// 저장이 끝나면 assignID로 화면의 메시지에 ID를 붙입니다. 보낸 사람이 음소거 중이면 오류를 반환합니다.
+func (h *Hub) Broadcast(msg Message) error {
+	if err := h.checkMuted(msg.Username); err != nil {
+		return err
+	}
+
+	msg = h.deliver(msg)
+	// 대기열이 가득 차면 저장만 건너뜁니다. 전달은 이미 끝났으므로 보낸 사람에게 오류를 알리지 않습니다.
+	_ = h.enqueue(func() {
+		if id := h.persist(msg); id > 0 {
+			h.assignID(msg, id)
+		}
+	})
+	return nil
+}
+
+// deliver는 메시지에 허브 안의 순번을 붙여 대화방에 남기고 방에 있는 모든 Client에게 전달합니다.
+// 순번을 붙인 메시지를 반환합니다.
+func (h *Hub) deliver(msg Message) Message {
+	h.mu.Lock()
+	h.seq++
+	msg.seq = h.seq
+	room, ok := h.rooms[msg.Room]
+	if !ok {
+		h.mu.Unlock()
+		return msg
+	}
+	room.messages = append(room.messages, msg)
+	if len(room.messages) > h.maxKeep {
+		room.messages = room.messages[len(room.messages)-h.maxKeep:]
+	}
+	clients := make([]*Client, 0, len(room.clients))
+	for c := range room.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.receive(msg)
+	}
+	return msg
+}
+
+// assignID는 먼저 전달한 msg에 저장한 뒤 정해진 DB ID를 붙입니다.
+// 대화방의 최근 메시지와, 메시지를 받았을 세션의 화면을 함께 고쳐야 이전 기록과 겹치지 않습니다.
+func (h *Hub) assignID(msg Message, id int64) {
+	h.mu.Lock()
+	var clients []*Client
+	if msg.Recipient != "" {
+		clients = append(h.sessionsOfLocked(msg.Recipient), h.sessionsOfLocked(msg.Username)...)
+	} else if room, ok := h.rooms[msg.Room]; ok {
+		for i := range room.messages {
+			if room.messages[i].seq == msg.seq {
+				room.messages[i].ID = id
+				break
+			}
+		}
+		clients = make([]*Client, 0, len(room.clients))
+		for c := range room.clients {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.assignID(msg, id)
+	}
+}
+
+// enqueue는 job을 쓰기 대기열에 넣습니다. 작업은 넣은 순서대로 하나씩 실행됩니다.
+func (h *Hub) enqueue(job func()) error {
+	select {
+	case h.writes <- job:
+		return nil
+	default:
+		h.logger.Error("[sshchat] write queue is full, dropping write")
+		return fmt.Errorf("the server is busy, try again in a moment")
+	}
+}
+
+func (h *Hub) writeLoop() {
+	for job := range h.writes {
+		job()
+	}
+}
+
+// Flush는 지금까지 쓰기 대기열에 넣은 작업이 모두 끝날 때까지 기다립니다.
+func (h *Hub) Flush() {
+	done := make(chan struct{})
+	h.writes <- func() { close(done) }
+	<-done
+}
+
+// Rooms는 현재 열려있는 대화방과 인원 수를 이름 순으로 반환합니다.
+func (h *Hub) Rooms() []RoomInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rooms := make([]RoomInfo, 0, len(h.rooms))
+	for name, room := range h.rooms {
+		rooms = append(rooms, RoomInfo{Name: name, Members: len(room.clients)})
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+
+	return rooms
+}
+
+// Members는 name 대화방에 있는 사용자 이름을 중복 없이 정렬해서 반환합니다.
+func (h *Hub) Members(name string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	room, ok := h.rooms[name]
+	if !ok {
+		return []string{}
+	}
+
+	seen := make(map[string]struct{}, len(room.clients))
+	members := make([]string, 0, len(room.clients))
+	for c := range room.clients {
+		if _, ok := seen[c.Username()]; ok {
+			continue
+		}
+		seen[c.Username()] = struct{}{}
+		members = append(members, c.Username())
+	}
+	sort.Strings(members)
+
+	return members
+}
+
+// Count는 현재 접속 중인 Client 수를 반환합니다.
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// persist는 메시지를 DB에 저장하고 ID를 반환합니다. 실패해도 채팅은 계속되도록 로그만 남기고 0을 반환합니다.
+func (h *Hub) persist(msg Message) int64 {
+	if h.db == nil {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := &db.Message{
+		Room:      msg.Room,
+		Timestamp: msg.Timestamp,
+		Username:  msg.Username,
+		Content:   msg.Content,
+	}
+	if err := db.InsertMessage(ctx, h.db, row); err != nil {
+		h.logger.Error("[sshchat] failed to persist message", "user", msg.Username, "room", msg.Room, "error", err)
+		return 0
+	}
+
+	return row.ID
+}
+
+func messageFromRow(row db.Message) Message {
+	return Message{
+		ID:        row.ID,
+		Room:      row.Room,
+		Timestamp: row.Timestamp,
+		Username:  row.Username,
+		Content:   row.Content,
+	}
+}
+
+// LoadOlder는 Client 화면에 있는 가장 오래된 메시지보다 이전 페이지를 DB에서 읽어 앞에 붙입니다.
+// 더 읽을 메시지가 없으면 이후로는 DB를 다시 조회하지 않도록 표시해 둡니다.
+func (h *Hub) LoadOlder(c *Client) {
+	c.mu.Lock()
+	if c.historyDone {
+		c.mu.Unlock()
+		return
+	}
+	oldest := oldestID(c.messages, c.peer != "")
+	room, peer := c.room, c.peer
+	c.mu.Unlock()
+
+	older := make([]Message, 0)
+	if h.db != nil && oldest > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		if peer != "" {
+			rows, err := db.GetDirectMessages(ctx, h.db, c.Username(), peer, oldest, h.historySize)
+			if err != nil {
+				h.logger.Error("[sshchat] failed to load older direct messages", "user", c.Username(), "peer", peer, "error", err)
+				return
+			}
+			for _, row := range rows {
+				older = append(older, directMessageFromRow(row))
+			}
+		} else {
+			rows, err := db.GetRecentMessages(ctx, h.db, room, oldest, h.historySize)
+			if err != nil {
+				h.logger.Error("[sshchat] failed to load older messages", "room", room, "error", err)
+				return
+			}
+			for _, row := range rows {
+				older = append(older, messageFromRow(row))
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 읽는 동안 다른 방이나 대화로 옮겨갔다면 버립니다.
+	if c.room != room || c.peer != peer {
+		return
+	}
+	if len(older) == 0 {
+		c.historyDone = true
+		return
+	}
+	c.messages = append(older, c.messages...)
+}
+
+// oldestID는 이전 페이지를 읽을 기준이 되는 가장 오래된 메시지 ID입니다. 없으면 0입니다.
+// 대화방 화면에도 개인 메시지가 섞여 들어오는데, 두 종류는 ID를 따로 매기므로 direct에 맞는 메시지만 봅니다.
+func oldestID(messages []Message, direct bool) int64 {
+	for _, msg := range messages {
+		if msg.ID > 0 && (msg.Recipient != "") == direct {
+			return msg.ID
+		}
+	}
+	return 0
+}
+
+// recentLocked는 room에 남아있는 최근 메시지를 historySize개까지 복사합니다. h.mu를 잡은 상태에서 호출해야 합니다.
+func (h *Hub) recentLocked(room *Room) []Message {
+	start := max(0, len(room.messages)-h.historySize)
+	messages := make([]Message, len(room.messages)-start)
+	copy(messages, room.messages[start:])
+	return messages
+}
+
+// loadHistory는 name 대화방의 최근 메시지를 DB에서 읽어 c의 화면에 합칩니다.
+// 읽는 동안 도착한 메시지는 뒤에 남기고, DB를 사용할 수 없으면 메모리에 있던 메시지를 그대로 둡니다.
+func (h *Hub) loadHistory(c *Client, name string) {
+	if h.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := db.GetRecentMessages(ctx, h.db, name, 0, h.historySize)
+	if err != nil {
+		h.logger.Error("[sshchat] failed to load history", "room", name, "error", err)
+		return
+	}
+	history := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		history = append(history, messageFromRow(row))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 읽는 동안 다른 방이나 대화로 옮겨갔다면 버립니다.
+	if c.room != name || c.peer != "" {
+		return
+	}
+	c.messages = mergeHistory(history, c.messages)
+}
+
+// mergeHistory는 history 뒤에 current 중 history에 없는 메시지를 붙입니다.
+// 대화방 메시지와 개인 메시지는 ID를 따로 매기므로 종류와 ID를 함께 비교하고, ID가 없는 시스템 메시지는 항상 남깁니다.
+func mergeHistory(history []Message, current []Message) []Message {
+	type key struct {
+		direct bool
+		id     int64
+	}
+	seen := make(map[key]struct{}, len(history))
+	for _, msg := range history {
+		seen[key{msg.Recipient != "", msg.ID}] = struct{}{}
+	}
+
+	merged := history
+	for _, msg := range current {
+		if msg.ID > 0 {
+			if _, ok := seen[key{msg.Recipient != "", msg.ID}]; ok {
+				continue
+			}
+		}
+		merged = append(merged, msg)
+	}
+	return merged
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// newTestHub는 DB 없이 메모리만 사용하는 Hub를 만듭니다.
+func newTestHub(config *Config) *Hub {
+	if config.HistorySize == 0 {
+		config.HistorySize = 100
+	}
+	return NewHub(nil, slog.New(slog.NewTextHandler(io.Discard, nil)), config)
+}
+
+// newTestClient는 SSH 세션 없이 허브에 등록할 수 있는 Client를 만듭니다.
+func newTestClient(username string, ipInfo *IpInfo) *Client {
+	return &Client{
+		username: username,
+		ip:       "192.0.2.1",
+		ipInfo:   ipInfo,
+		input:    Input{MaxLen: 128},
+		messages: make([]Message, 0),
+		theme:    lookupTheme(DefaultTheme),
+		RenderCh: make(chan struct{}, 1),
+	}
+}
+
+// chatMessages는 c의 화면에 있는 시스템 메시지가 아닌 메시지 내용입니다.
+func chatMessages(c *Client) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	contents := make([]string, 0)
+	for _, msg := range c.messages {
+		if !msg.System {
+			contents = append(contents, msg.Content)
+		}
+	}
+	return contents
+}
+
+func TestOldestID(t *testing.T) {
+	messages := []Message{
+		{Content: "welcome", System: true},
+		{ID: 900, Username: "bob", Recipient: "alice", Content: "dm"},
+		{ID: 41, Room: DefaultRoom, Username: "bob", Content: "room"},
+		{ID: 42, Room: DefaultRoom, Username: "carol", Content: "room"},
+		{ID: 901, Username: "alice", Recipient: "bob", Content: "dm"},
+	}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		direct   bool
+		want     int64
+	}{
+		{name: "room view skips direct messages", messages: messages, direct: false, want: 41},
+		{name: "direct view skips room messages", messages: messages, direct: true, want: 900},
+		{name: "only system messages", messages: messages[:1], direct: false, want: 0},
+		{name: "empty", messages: nil, direct: false, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := oldestID(tt.messages, tt.direct); got != tt.want {
+				t.Fatalf("oldestID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeHistory(t *testing.T) {
+	history := []Message{
+		{ID: 1, Room: DefaultRoom, Content: "a"},
+		{ID: 2, Room: DefaultRoom, Content: "b"},
+	}
+	current := []Message{
+		{ID: 2, Room: DefaultRoom, Content: "b"},   // 불러오기 전에 받은 메시지
+		{Content: "bob joined", System: true},      // ID 없는 시스템 메시지
+		{ID: 2, Recipient: "alice", Content: "dm"}, // 같은 ID의 개인 메시지
+		{ID: 3, Room: DefaultRoom, Content: "c"},   // 불러오는 동안 도착한 메시지
+	}
+
+	got := make([]string, 0)
+	for _, msg := range mergeHistory(history, current) {
+		got = append(got, msg.Content)
+	}
+	want := []string{"a", "b", "bob joined", "dm", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mergeHistory = %q, want %q", got, want)
+	}
+}
+
+func TestJoinShowsEachMessageOnce(t *testing.T) {
+	hub := newTestHub(&Config{})
+	alice := newTestClient("alice", nil)
+	bob := newTestClient("bob", nil)
+	for _, c := range []*Client{alice, bob} {
+		if err := hub.Register(c); err != nil {
+			t.Fatalf("Register(%s): %v", c.Username(), err)
+		}
+	}
+	if err := hub.Join(alice, "dev"); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	// bob이 입장하는 동안 alice가 계속 메시지를 보내도 bob의 화면에는 한 번씩만 보여야 합니다.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			_ = hub.Broadcast(Message{Room: "dev", Username: "alice", Content: fmt.Sprint(i)})
+		}
+	}()
+	if err := hub.Join(bob, "dev"); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	<-done
+	hub.Flush()
+
+	got := chatMessages(bob)
+	if len(got) == 0 {
+		t.Fatalf("bob saw no messages")
+	}
+	first, _ := strconv.Atoi(got[0])
+	for i, content := range got {
+		if content != fmt.Sprint(first+i) {
+			t.Fatalf("bob messages = %q, want a gapless run without duplicates", got)
+		}
+	}
+	if got[len(got)-1] != "199" {
+		t.Fatalf("last message = %q, want 199", got[len(got)-1])
+	}
+}
+
+func TestBroadcastDoesNotWaitForWrites(t *testing.T) {
+	hub := newTestHub(&Config{})
+	alice := newTestClient("alice", nil)
+	if err := hub.Register(alice); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// DB가 멈춘 것처럼 쓰기 대기열을 막아 둡니다.
+	started, release := make(chan struct{}), make(chan struct{})
+	if err := hub.enqueue(func() { close(started); <-release }); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	<-started
+	defer close(release)
+
+	// 저장을 기다리지 않고 바로 전달하고, 대기열이 가득 차도 대화는 계속됩니다.
+	n := writeQueueSize + 10
+	for i := 0; i < n; i++ {
+		if err := hub.Broadcast(Message{Room: DefaultRoom, Username: "alice", Content: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Broadcast %d: %v", i, err)
+		}
+	}
+	if got := chatMessages(alice); len(got) != n {
+		t.Fatalf("delivered %d messages, want %d", len(got), n)
+	}
+}
+
+func TestAssignID(t *testing.T) {
+	hub := newTestHub(&Config{})
+	alice := newTestClient("alice", nil)
+	if err := hub.Register(alice); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	first := hub.deliver(Message{Room: DefaultRoom, Username: "alice", Content: "first"})
+	second := hub.deliver(Message{Room: DefaultRoom, Username: "alice", Content: "second"})
+
+	// 저장이 끝나면 대화방의 최근 메시지와 화면의 메시지에 ID가 붙습니다.
+	hub.assignID(first, 41)
+	hub.mu.RLock()
+	roomID := hub.rooms[DefaultRoom].messages[0].ID
+	hub.mu.RUnlock()
+	if roomID != 41 {
+		t.Fatalf("room message ID = %d, want 41", roomID)
+	}
+
+	// 저장이 끝나기 전에 DB에서 같은 메시지를 불러왔으면 먼저 전달받은 쪽을 지웁니다.
+	alice.mu.Lock()
+	alice.messages = mergeHistory([]Message{{ID: 41, Room: DefaultRoom, Username: "alice", Content: "first"}, {ID: 42, Room: DefaultRoom, Username: "alice", Content: "second"}}, alice.messages)
+	alice.mu.Unlock()
+	hub.assignID(second, 42)
+
+	ids := make([]int64, 0)
+	alice.mu.Lock()
+	for _, msg := range alice.messages {
+		if !msg.System {
+			ids = append(ids, msg.ID)
+		}
+	}
+	alice.mu.Unlock()
+	if want := []int64{41, 42}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("message IDs = %v, want %v", ids, want)
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "alice"},
+		{name: "Bob_2"},
+		{name: "a-b"},
+		{name: "", wantErr: true},
+		{name: "-alice", wantErr: true},
+		{name: "alice bob", wantErr: true},
+		{name: "alice@home", wantErr: true},
+		{name: "user:bob", wantErr: true},
+		{name: "evil\x1b[2J", wantErr: true},
+		{name: "bell\a", wantErr: true},
+		{name: "한글", wantErr: true},
+		{name: "System", wantErr: true},
+		{name: "abcdefghijklmnopqrstuvwxyz0123456", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.name), func(t *testing.T) {
+			if err := ValidateUsername(tt.name); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateUsername(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
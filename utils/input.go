@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"unicode"
+)
+
+// maxInputHistory는 위/아래 화살표로 불러올 수 있는 입력 기록의 최대 개수입니다.
+const maxInputHistory = 100
+
+// maxPasteLen은 붙여넣기로 입력할 수 있는 최대 룬 수입니다. 직접 입력할 때는 MaxLen을 따릅니다.
+const maxPasteLen = 4096
+
+// Input은 프롬프트의 편집 상태입니다. Cursor는 Buffer 안의 룬 위치이며 항상 글자 경계에 있습니다.
+type Input struct {
+	Buffer []rune
+	MaxLen int
+	Cursor int
+
+	history    []string
+	historyIdx int    // len(history)이면 기록이 아닌 새 입력을 편집 중
+	draft      []rune // 기록을 탐색하기 전 편집하던 내용
+
+	completion *completion // Tab을 연속으로 누르는 중이면 nil이 아님
+	pastedLead bool        // 맨 앞 글자가 붙여넣기로 들어왔으면 true. 이 줄은 명령으로 실행하지 않습니다.
+}
+
+// completion은 Tab을 연속으로 눌렀을 때 후보를 차례로 바꿔 넣기 위한 상태입니다.
+type completion struct {
+	start      int      // 완성 중인 단어가 시작하는 룬 위치
+	candidates []string // 단어 자리에 넣을 문자열
+	idx        int
+}
+
+// Insert는 커서 위치에 r을 넣습니다. 버퍼가 가득 차 있으면 false를 반환합니다.
+func (in *Input) Insert(r rune) bool {
+	if len(in.Buffer) >= in.MaxLen {
+		return false
+	}
+
+	return in.insert(r, false)
+}
+
+// Paste는 붙여넣은 글자 r을 커서 위치에 넣습니다. 줄 바꿈도 그대로 넣고, maxPasteLen까지 허용합니다.
+func (in *Input) Paste(r rune) bool {
+	if len(in.Buffer) >= max(in.MaxLen, maxPasteLen) {
+		return false
+	}
+
+	return in.insert(r, true)
+}
+
+func (in *Input) insert(r rune, pasted bool) bool {
+	if in.Cursor == 0 {
+		in.pastedLead = pasted
+	}
+	in.Buffer = append(in.Buffer, 0)
+	copy(in.Buffer[in.Cursor+1:], in.Buffer[in.Cursor:])
+	in.Buffer[in.Cursor] = r
+	in.Cursor++
+
+	return true
+}
+
+// prevBoundary와 nextBoundary는 커서 앞뒤의 글자(grapheme cluster) 경계를 찾습니다.
+// 결합 문자나 ZWJ 이모지를 룬 단위로 쪼개지 않기 위해 사용합니다.
+func (in *Input) prevBoundary() int {
+	prev := 0
+	for _, b := range graphemeBoundaries(in.Buffer) {
+		if b >= in.Cursor {
+			break
+		}
+		prev = b
+	}
+	return prev
+}
+
+func (in *Input) nextBoundary() int {
+	for _, b := range graphemeBoundaries(in.Buffer) {
+		if b > in.Cursor {
+			return b
+		}
+	}
+	return len(in.Buffer)
+}
+
+// Backspace는 커서 앞의 글자 하나를 지웁니다.
+func (in *Input) Backspace() bool {
+	if in.Cursor == 0 {
+		return false
+	}
+
+	prev := in.prevBoundary()
+	in.Buffer = append(in.Buffer[:prev], in.Buffer[in.Cursor:]...)
+	in.Cursor = prev
+
+	return true
+}
+
+// Delete는 커서 위치의 글자 하나를 지웁니다.
+func (in *Input) Delete() bool {
+	if in.Cursor >= len(in.Buffer) {
+		return false
+	}
+
+	in.Buffer = append(in.Buffer[:in.Cursor], in.Buffer[in.nextBoundary():]...)
+
+	return true
+}
+
+func (in *Input) Left() bool {
+	if in.Cursor == 0 {
+		return false
+	}
+	in.Cursor = in.prevBoundary()
+	return true
+}
+
+func (in *Input) Right() bool {
+	if in.Cursor >= len(in.Buffer) {
+		return false
+	}
+	in.Cursor = in.nextBoundary()
+	return true
+}
+
+func (in *Input) Home() bool {
+	if in.Cursor == 0 {
+		return false
+	}
+	in.Cursor = 0
+	return true
+}
+
+func (in *Input) End() bool {
+	if in.Cursor == len(in.Buffer) {
+		return false
+	}
+	in.Cursor = len(in.Buffer)
+	return true
+}
+
+// KillToEnd는 커서부터 줄 끝까지 지웁니다. (Ctrl+K)
+func (in *Input) KillToEnd() bool {
+	if in.Cursor >= len(in.Buffer) {
+		return false
+	}
+	in.Buffer = in.Buffer[:in.Cursor]
+	return true
+}
+
+// KillToStart는 줄 처음부터 커서 앞까지 지웁니다. (Ctrl+U)
+func (in *Input) KillToStart() bool {
+	if in.Cursor == 0 {
+		return false
+	}
+	in.Buffer = append(in.Buffer[:0], in.Buffer[in.Cursor:]...)
+	in.Cursor = 0
+	return true
+}
+
+// DeleteWord는 커서 앞의 공백과 단어 하나를 지웁니다. (Ctrl+W)
+func (in *Input) DeleteWord() bool {
+	if in.Cursor == 0 {
+		return false
+	}
+
+	start := in.Cursor
+	for start > 0 && unicode.IsSpace(in.Buffer[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(in.Buffer[start-1]) {
+		start--
+	}
+
+	in.Buffer = append(in.Buffer[:start], in.Buffer[in.Cursor:]...)
+	in.Cursor = start
+
+	return true
+}
+
+// HistoryPrev는 이전에 보낸 줄을 불러옵니다. (Up)
+func (in *Input) HistoryPrev() bool {
+	if in.historyIdx == 0 {
+		return false
+	}
+	if in.historyIdx == len(in.history) {
+		in.draft = append(in.draft[:0], in.Buffer...)
+	}
+
+	in.historyIdx--
+	in.setBuffer([]rune(in.history[in.historyIdx]))
+
+	return true
+}
+
+// HistoryNext는 다음 기록이나, 기록의 끝이면 편집하던 내용을 불러옵니다. (Down)
+func (in *Input) HistoryNext() bool {
+	if in.historyIdx >= len(in.history) {
+		return false
+	}
+
+	in.historyIdx++
+	if in.historyIdx == len(in.history) {
+		in.setBuffer(in.draft)
+	} else {
+		in.setBuffer([]rune(in.history[in.historyIdx]))
+	}
+
+	return true
+}
+
+// Submit은 현재 줄을 반환하고 입력 기록에 추가한 뒤 버퍼를 비웁니다.
+// pasted는 줄이 붙여넣은 내용으로 시작했는지 여부입니다.
+func (in *Input) Submit() (line string, pasted bool) {
+	line = string(in.Buffer)
+	pasted = in.pastedLead
+
+	if line != "" && (len(in.history) == 0 || in.history[len(in.history)-1] != line) {
+		in.history = append(in.history, line)
+		if len(in.history) > maxInputHistory {
+			in.history = in.history[len(in.history)-maxInputHistory:]
+		}
+	}
+	in.historyIdx = len(in.history)
+	in.draft = in.draft[:0]
+
+	in.Buffer = in.Buffer[:0]
+	in.Cursor = 0
+	in.pastedLead = false
+
+	return line, pasted
+}
+
+// WordBeforeCursor는 커서 바로 앞의 공백 없는 단어와 그 시작 위치를 반환합니다.
+func (in *Input) WordBeforeCursor() (int, string) {
+	start := in.Cursor
+	for start > 0 && !unicode.IsSpace(in.Buffer[start-1]) {
+		start--
+	}
+
+	return start, string(in.Buffer[start:in.Cursor])
+}
+
+// StartCompletion은 start부터 커서까지의 단어를 첫 번째 후보로 바꾸고, 다음 Tab에서 이어서 바꿀 수 있도록 기억합니다.
+func (in *Input) StartCompletion(start int, candidates []string) bool {
+	if len(candidates) == 0 || start > in.Cursor {
+		return false
+	}
+
+	in.completion = &completion{start: start, candidates: candidates}
+	return in.replaceWord(candidates[0])
+}
+
+// CycleCompletion은 바로 전에 넣은 후보를 다음 후보로 바꿉니다. 완성 중이 아니면 false를 반환합니다.
+func (in *Input) CycleCompletion() bool {
+	if in.completion == nil {
+		return false
+	}
+
+	in.completion.idx = (in.completion.idx + 1) % len(in.completion.candidates)
+	in.replaceWord(in.completion.candidates[in.completion.idx])
+	return true
+}
+
+// ResetCompletion은 Tab 이외의 키를 누르면 호출되어, 다음 Tab이 새로 완성을 시작하게 합니다.
+func (in *Input) ResetCompletion() {
+	in.completion = nil
+}
+
+func (in *Input) replaceWord(s string) bool {
+	word := []rune(s)
+	start := in.completion.start
+	if len(in.Buffer)-(in.Cursor-start)+len(word) > max(in.MaxLen, maxPasteLen) {
+		return false
+	}
+
+	rest := append([]rune{}, in.Buffer[in.Cursor:]...)
+	in.Buffer = append(append(in.Buffer[:start], word...), rest...)
+	in.Cursor = start + len(word)
+
+	return true
+}
+
+func (in *Input) setBuffer(r []rune) {
+	// 기록에는 붙여넣은 긴 메시지도 있으므로 붙여넣기 한도까지 허용합니다.
+	in.Buffer = append(in.Buffer[:0], r...)
+	in.pastedLead = false
+	if limit := max(in.MaxLen, maxPasteLen); len(in.Buffer) > limit {
+		in.Buffer = in.Buffer[:limit]
+	}
+	in.Cursor = len(in.Buffer)
+}
+
+// maxEscapeLen은 CSI 시퀀스의 최대 길이입니다. 종료 바이트 없이 이보다 길어지면 버립니다.
+const maxEscapeLen = 32
+
+type escapeState int
+
+const (
+	escIdle  escapeState = iota
+	escStart             // ESC를 받음
+	escCSI               // ESC [ 를 받음
+	escSS3               // ESC O 를 받음
+)
+
+// escapeParser는 ESC로 시작하는 시퀀스를 한 글자씩 받아 "[A", "[3~", "OH" 같은 문자열로 조립합니다.
+// SSH 패킷 경계에서 시퀀스가 나뉘어 도착해도 다음 읽기까지 상태를 유지합니다.
+type escapeParser struct {
+	state escapeState
+	seq   []rune
+}
+
+// Feed는 r을 파서에 넣습니다. r이 시퀀스의 일부가 아니면 consumed가 false이고 일반 입력으로 처리해야 합니다.
+// 시퀀스가 끝나면 seq에 시퀀스를 반환하고, 아직 진행 중이면 빈 문자열을 반환합니다.
+func (p *escapeParser) Feed(r rune) (seq string, consumed bool) {
+	if r == 0x1b {
+		// 끝나지 않은 시퀀스 중간에 ESC가 오면 앞의 것은 버리고 새로 시작합니다.
+		p.state = escStart
+		p.seq = p.seq[:0]
+		return "", true
+	}
+
+	switch p.state {
+	case escStart:
+		switch r {
+		case '[':
+			p.state = escCSI
+		case 'O':
+			p.state = escSS3
+		default:
+			// ESC 단독이나 Alt+키는 무시하고 뒤의 글자는 일반 입력으로 처리합니다.
+			p.state = escIdle
+			return "", false
+		}
+		p.seq = append(p.seq[:0], r)
+		return "", true
+	case escCSI:
+		// CSI: 파라미터 바이트(0x20-0x3F) 뒤에 종료 바이트(0x40-0x7E)가 옵니다.
+		// 그 밖의 글자가 오면 시퀀스를 버리고 그 글자는 일반 입력으로 처리합니다.
+		if r < 0x20 || r > 0x7e {
+			p.state = escIdle
+			return "", false
+		}
+		p.seq = append(p.seq, r)
+		if r >= 0x40 {
+			return p.finish(), true
+		}
+		if len(p.seq) > maxEscapeLen {
+			p.state = escIdle
+		}
+		return "", true
+	case escSS3:
+		// SS3: 한 글자가 더 옵니다. (일부 터미널의 Home/End, 화살표)
+		if r < 0x20 || r > 0x7e {
+			p.state = escIdle
+			return "", false
+		}
+		p.seq = append(p.seq, r)
+		return p.finish(), true
+	default:
+		return "", false
+	}
+}
+
+func (p *escapeParser) finish() string {
+	p.state = escIdle
+	return string(p.seq)
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInputGraphemeEditing(t *testing.T) {
+	in := Input{MaxLen: 128}
+	for _, r := range "a한e\u0301" {
+		in.Insert(r)
+	}
+
+	// 결합 문자는 앞 글자와 함께 지워져야 합니다.
+	in.Backspace()
+	if got := string(in.Buffer); got != "a한" {
+		t.Fatalf("after Backspace buffer = %q, want %q", got, "a한")
+	}
+
+	in.Left()
+	if in.Cursor != 1 {
+		t.Fatalf("after Left cursor = %d, want 1", in.Cursor)
+	}
+	if got := displayWidth(string(in.Buffer[:in.Cursor])); got != 1 {
+		t.Fatalf("cursor column = %d, want 1", got)
+	}
+
+	in.Insert('나')
+	if got := string(in.Buffer); got != "a나한" {
+		t.Fatalf("after Insert buffer = %q, want %q", got, "a나한")
+	}
+	if got := displayWidth(string(in.Buffer[:in.Cursor])); got != 3 {
+		t.Fatalf("cursor column = %d, want 3", got)
+	}
+}
+
+func TestInputPaste(t *testing.T) {
+	in := Input{MaxLen: 4}
+	for _, r := range "line1\nline2" {
+		if !in.Paste(r) {
+			t.Fatalf("Paste(%q) rejected", r)
+		}
+	}
+	if got := string(in.Buffer); got != "line1\nline2" {
+		t.Fatalf("buffer = %q, want %q", got, "line1\nline2")
+	}
+	if in.Insert('x') {
+		t.Fatalf("Insert accepted past MaxLen")
+	}
+	if got := promptText(in.Buffer); got != "line1↵line2" {
+		t.Fatalf("promptText = %q, want %q", got, "line1↵line2")
+	}
+}
+
+func TestEscapeParser(t *testing.T) {
+	type fed struct {
+		seq      string
+		consumed bool
+	}
+	tests := []struct {
+		name string
+		in   string
+		want []fed
+	}{
+		{
+			name: "arrow",
+			in:   "\x1b[A",
+			want: []fed{{"", true}, {"", true}, {"[A", true}},
+		},
+		{
+			name: "paste end marker",
+			in:   "\x1b[201~",
+			want: []fed{{"", true}, {"", true}, {"", true}, {"", true}, {"", true}, {"[201~", true}},
+		},
+		{
+			name: "ss3",
+			in:   "\x1bOH",
+			want: []fed{{"", true}, {"", true}, {"OH", true}},
+		},
+		{
+			name: "alt key falls through",
+			in:   "\x1bab",
+			want: []fed{{"", true}, {"", false}, {"", false}},
+		},
+		{
+			name: "broken csi releases control char",
+			in:   "\x1b[1\r",
+			want: []fed{{"", true}, {"", true}, {"", true}, {"", false}},
+		},
+		{
+			name: "esc restarts sequence",
+			in:   "\x1b[2\x1b[B",
+			want: []fed{{"", true}, {"", true}, {"", true}, {"", true}, {"", true}, {"[B", true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p escapeParser
+			for i, r := range []rune(tt.in) {
+				seq, consumed := p.Feed(r)
+				if got := (fed{seq, consumed}); got != tt.want[i] {
+					t.Fatalf("Feed(%q) at %d = %+v, want %+v", r, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 패킷 경계에서 나뉘어 도착해도 파서 상태가 유지되는지 확인합니다.
+func TestEscapeParserSplitReads(t *testing.T) {
+	var p escapeParser
+	got := make([]string, 0)
+	for _, chunk := range []string{"abc\x1b", "[20", "1~de"} {
+		for _, r := range chunk {
+			if seq, consumed := p.Feed(r); consumed && seq != "" {
+				got = append(got, seq)
+			}
+		}
+	}
+	if len(got) != 1 || got[0] != "[201~" {
+		t.Fatalf("sequences = %q, want [\"[201~\"]", got)
+	}
+}
+
+func TestInputSubmitPasted(t *testing.T) {
+	tests := []struct {
+		name   string
+		typed  string
+		pasted string
+		want   bool
+	}{
+		{"typed", "/help", "", false},
+		{"pasted", "", "/help", true},
+		{"typed command then paste", "/msg bob ", "hi", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := Input{MaxLen: 128}
+			for _, r := range tt.typed {
+				in.Insert(r)
+			}
+			for _, r := range tt.pasted {
+				in.Paste(r)
+			}
+			line, pasted := in.Submit()
+			if line != tt.typed+tt.pasted || pasted != tt.want {
+				t.Fatalf("Submit = (%q, %v), want (%q, %v)", line, pasted, tt.typed+tt.pasted, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadInputLongPaste(t *testing.T) {
+	long := strings.Repeat("a", maxPasteLen+100)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string // 대화방에 보낸 메시지
+	}{
+		{name: "with end marker", input: "\x1b[200~" + long + "\n/part\x1b[201~\r", want: []string{long[:maxPasteLen]}},
+		{name: "lost end marker", input: "\x1b[200~" + long + "\n/part\r\n/part\r", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newTestHub(&Config{})
+			c := newTestClient("alice", &IpInfo{Country: "KR"})
+			if err := hub.Register(c); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			if err := hub.Join(c, "help"); err != nil {
+				t.Fatalf("Join(help): %v", err)
+			}
+
+			// 한도를 넘은 뒤의 줄 바꿈도 Enter가 아니므로 붙여넣은 /part는 실행되지 않습니다.
+			c.readInput(context.Background(), strings.NewReader(tt.input))
+			hub.Flush()
+			if c.Room() != "help" {
+				t.Fatalf("room = %q, pasted /part ran as a command", c.Room())
+			}
+			if got := chatMessages(c); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("sent %d messages, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestReadInputPasteIdleTimeout(t *testing.T) {
+	hub := newTestHub(&Config{})
+	c := newTestClient("alice", &IpInfo{Country: "KR"})
+	if err := hub.Register(c); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := hub.Join(c, "help"); err != nil {
+		t.Fatalf("Join(help): %v", err)
+	}
+
+	c.readInput(context.Background(), strings.NewReader("\x1b[200~/part"))
+	c.mu.Lock()
+	c.pasteAt = time.Now().Add(-pasteIdleTimeout - time.Second)
+	c.mu.Unlock()
+
+	// 끝 표시 없이 입력이 멈추면 다음 Enter부터 직접 입력으로 받지만, 붙여넣은 줄은 명령으로 실행하지 않습니다.
+	c.readInput(context.Background(), strings.NewReader("\r"))
+	hub.Flush()
+	if c.Room() != "help" {
+		t.Fatalf("room = %q, pasted /part ran as a command", c.Room())
+	}
+	if got := chatMessages(c); len(got) != 1 || got[0] != "/part" {
+		t.Fatalf("messages = %q, want [\"/part\"]", got)
+	}
+
+	c.readInput(context.Background(), strings.NewReader("/part\r"))
+	if c.Room() != DefaultRoom {
+		t.Fatalf("room = %q, typed /part did not run", c.Room())
+	}
+}
+
+func TestReadInputDropsControls(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "typed C1 and DEL", input: "a\u009b2Jb\u0085c\r", want: "a2Jbc"},
+		{name: "pasted C1", input: "\x1b[200~x\u009b2J\ty\x1b[201~\r", want: "x2J\ty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newTestHub(&Config{})
+			c := newTestClient("alice", &IpInfo{Country: "KR"})
+			if err := hub.Register(c); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+
+			c.readInput(context.Background(), strings.NewReader(tt.input))
+			if got := chatMessages(c); len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("messages = %q, want [%q]", got, tt.want)
+			}
+		})
+	}
+}
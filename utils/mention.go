@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"sshchat/db"
+)
+
+// DefaultNotify는 프로필에 알림 방식이 없을 때 사용하는 방식입니다.
+const DefaultNotify = "bell"
+
+// notifyModes는 /notify로 고를 수 있는 멘션 알림 방식입니다.
+// osc9(iTerm2, Windows Terminal 등)와 osc777(urxvt, foot 등)은 BEL과 함께 데스크톱 알림을 보냅니다.
+var notifyModes = map[string]struct{}{
+	"off":    {},
+	"bell":   {},
+	"osc9":   {},
+	"osc777": {},
+}
+
+// NotifyModeNames는 선택할 수 있는 알림 방식을 정렬해서 반환합니다.
+func NotifyModeNames() []string {
+	names := make([]string, 0, len(notifyModes))
+	for name := range notifyModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// maxMentions는 /mentions가 한 번에 보여주는 최대 메시지 수입니다.
+const maxMentions = 50
+
+// isNickRune은 사용자 이름의 일부로 볼 글자인지 확인합니다. 멘션의 앞뒤 경계를 판단할 때 사용합니다.
+func isNickRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// mentionSpans는 content에서 "@username"이 단어로 쓰인 위치를 바이트 범위로 반환합니다.
+// 대소문자는 구분하지 않고, "@alice2"나 "bob@alice" 같은 경우는 멘션으로 보지 않습니다.
+func mentionSpans(content string, username string) [][2]int {
+	if username == "" {
+		return nil
+	}
+
+	target := "@" + username
+	spans := make([][2]int, 0)
+	for i := 0; i+len(target) <= len(content); i++ {
+		if content[i] != '@' || !strings.EqualFold(content[i:i+len(target)], target) {
+			continue
+		}
+		end := i + len(target)
+		if before, _ := utf8.DecodeLastRuneInString(content[:i]); i > 0 && isNickRune(before) {
+			continue
+		}
+		if after, _ := utf8.DecodeRuneInString(content[end:]); end < len(content) && isNickRune(after) {
+			continue
+		}
+
+		spans = append(spans, [2]int{i, end})
+		i = end - 1
+	}
+
+	return spans
+}
+
+// mentions는 msg가 username을 부르는 다른 사람의 메시지인지 확인합니다.
+func mentions(msg Message, username string) bool {
+	return !msg.System && msg.Username != username && len(mentionSpans(msg.Content, username)) > 0
+}
+
+// highlightMentions는 line 안의 "@username"에 멘션 색을 입힙니다.
+func (p painter) highlightMentions(line string) string {
+	spans := mentionSpans(line, p.self)
+	if len(spans) == 0 {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(line[last:span[0]])
+		b.WriteString(p.paint(line[span[0]:span[1]], p.theme.Mention))
+		last = span[1]
+	}
+	b.WriteString(line[last:])
+
+	return b.String()
+}
+
+// notificationText는 알림에 넣을 문자열에서 제어 문자를 지우고 길이를 줄입니다.
+func notificationText(s string, w int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r < 0xa0) {
+			return ' '
+		}
+		return r
+	}, s)
+
+	return truncateWidth(s, w)
+}
+
+// notifyMention은 멘션을 받았을 때 고른 방식으로 터미널 알림을 보냅니다.
+func (c *Client) notifyMention(msg Message) {
+	c.mu.Lock()
+	mode := c.notify
+	c.mu.Unlock()
+
+	if mode == "off" || c.session == nil {
+		return
+	}
+
+	where := "#" + msg.Room
+	if msg.Recipient != "" {
+		where = "@" + msg.Recipient
+	}
+	body := notificationText(fmt.Sprintf("%s: %s", msg.Username, msg.Content), 120)
+
+	seq := "\a"
+	switch mode {
+	case "osc9":
+		seq += "\x1b]9;" + notificationText(where, 40) + " " + body + "\a"
+	case "osc777":
+		title := strings.ReplaceAll(notificationText("sshchat "+where, 40), ";", " ")
+		seq += "\x1b]777;notify;" + title + ";" + body + "\a"
+	}
+	// 메시지는 보낸 사람의 입력 goroutine에서 전달되므로, 느린 터미널 하나가 보낸 사람의 입력을 막지 않도록 따로 보냅니다.
+	go c.screen.send(c.session, seq)
+}
+
+// Mentions는 username을 부른 최근 대화방 메시지를 최대 limit개 반환합니다.
+// DB를 사용할 수 없으면 메모리에 남아있는 메시지에서 찾습니다.
+func (h *Hub) Mentions(username string, limit int) []Message {
+	found := make([]Message, 0)
+	if h.db != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		rows, err := db.GetMentions(ctx, h.db, username, limit)
+		if err == nil {
+			for _, row := range rows {
+				if msg := messageFromRow(row); mentions(msg, username) {
+					found = append(found, msg)
+				}
+			}
+			return found
+		}
+		h.logger.Error("[sshchat] failed to load mentions", "user", username, "error", err)
+	}
+
+	h.mu.RLock()
+	for _, room := range h.rooms {
+		for _, msg := range room.messages {
+			if mentions(msg, username) {
+				found = append(found, msg)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Timestamp.Before(found[j].Timestamp) })
+	if len(found) > limit {
+		found = found[len(found)-limit:]
+	}
+
+	return found
+}
+
+// saveNotify는 Client의 알림 방식을 바꾸고 프로필에 저장합니다.
+func (h *Hub) saveNotify(c *Client, mode string) error {
+	err := h.updateProfile(c, "notify", func(ctx context.Context) error {
+		return db.SetUserNotify(ctx, h.db, c.Username(), mode)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.setNotify(mode)
+	return nil
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:       "mentions",
+		Usage:      "/mentions [count]",
+		Help:       "list recent messages that mention you",
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			limit := 10
+			switch len(args) {
+			case 0:
+			case 1:
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return usageError(commands["mentions"])
+				}
+				limit = min(n, maxMentions)
+			default:
+				return usageError(commands["mentions"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			found := hub.Mentions(c.Username(), limit)
+			if len(found) == 0 {
+				c.systemMessage("no one has mentioned you yet")
+				return nil
+			}
+
+			c.mu.Lock()
+			ck := c.clock
+			c.mu.Unlock()
+
+			lines := make([]string, 0, len(found)+1)
+			lines = append(lines, fmt.Sprintf("%d recent mentions:", len(found)))
+			for _, msg := range found {
+				lines = append(lines, fmt.Sprintf("  #%s [%s %s] %s", msg.Room, ck.format(msg.Timestamp), msg.Username, msg.Content))
+			}
+			c.systemMessage("%s", strings.Join(lines, "\n"))
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:  "notify",
+		Usage: "/notify [" + strings.Join(NotifyModeNames(), "|") + "]",
+		Help:  "show or change how you are notified of mentions",
+		Run: func(c *Client, args []string) error {
+			switch len(args) {
+			case 0:
+				c.mu.Lock()
+				mode := c.notify
+				c.mu.Unlock()
+				c.systemMessage("notify: %s (available: %s)", mode, strings.Join(NotifyModeNames(), ", "))
+				return nil
+			case 1:
+			default:
+				return usageError(commands["notify"])
+			}
+
+			mode := strings.ToLower(args[0])
+			if _, ok := notifyModes[mode]; !ok {
+				return fmt.Errorf("unknown notify mode %q: use %s", args[0], strings.Join(NotifyModeNames(), ", "))
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			if err := hub.saveNotify(c, mode); err != nil {
+				return err
+			}
+
+			c.systemMessage("notify set to %s", mode)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,758 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sshchat/db"
+)
+
+// Role은 사용자의 권한 수준입니다. 값이 클수록 높은 권한입니다.
+type Role int
+
+const (
+	RoleMember Role = iota
+	RoleModerator
+	RoleAdmin
+	RoleOwner
+)
+
+var roleNames = []string{"member", "moderator", "admin", "owner"}
+
+func (r Role) String() string {
+	if r < RoleMember || r > RoleOwner {
+		return "member"
+	}
+	return roleNames[r]
+}
+
+// ParseRole은 DB나 명령에 쓰인 역할 이름을 Role로 바꿉니다.
+func ParseRole(name string) (Role, error) {
+	i := slices.Index(roleNames, strings.ToLower(name))
+	if i < 0 {
+		return RoleMember, fmt.Errorf("unknown role %q: use %s", name, strings.Join(roleNames, ", "))
+	}
+	return Role(i), nil
+}
+
+// 제재 대상의 종류
+const (
+	targetUser = "user"
+	targetIP   = "ip"
+	targetKey  = "key"
+)
+
+// parseTarget은 /ban의 대상을 종류와 값으로 나눕니다. "ip:", "key:", "user:" 접두사로 종류를 정할 수 있고,
+// 접두사가 없으면 IP 주소는 ip, "SHA256:"으로 시작하면 공개키 지문, 나머지는 사용자 이름으로 봅니다.
+func parseTarget(s string) (string, string, error) {
+	kind, value, found := strings.Cut(s, ":")
+	switch {
+	case found && (kind == targetUser || kind == targetIP || kind == targetKey):
+	case strings.HasPrefix(s, "SHA256:"):
+		kind, value = targetKey, s
+	default:
+		if _, err := netip.ParseAddr(s); err == nil {
+			kind, value = targetIP, s
+		} else {
+			kind, value = targetUser, s
+		}
+	}
+
+	if kind == targetIP {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid IP address %q", value)
+		}
+		value = addr.Unmap().String()
+	}
+	if value == "" {
+		return "", "", fmt.Errorf("empty %s", kind)
+	}
+
+	return kind, value, nil
+}
+
+// NormalizeIP는 IPv4-mapped IPv6 주소를 IPv4로 바꿔서, 같은 주소가 항상 같은 문자열이 되게 합니다.
+// 주소가 아니면 그대로 반환합니다.
+func NormalizeIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	return addr.Unmap().String()
+}
+
+// parseDuration은 "30m", "12h" 같은 time.ParseDuration 형식에 더해 "7d", "2w"를 받습니다.
+func parseDuration(s string) (time.Duration, bool) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit > 0 {
+		n, err := strconv.Atoi(strings.TrimRight(s, "dw"))
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return time.Duration(n) * unit, true
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// splitDurationReason은 "[duration] [reason]" 형태의 인자를 나눕니다. 기간이 없으면 0(영구)입니다.
+func splitDurationReason(rest string) (time.Duration, string) {
+	first, remain, _ := strings.Cut(rest, " ")
+	if d, ok := parseDuration(first); ok {
+		return d, strings.TrimSpace(remain)
+	}
+	return 0, rest
+}
+
+// expiryText는 제재 기간을 사람이 읽을 수 있게 표시합니다.
+func expiryText(d time.Duration) string {
+	if d <= 0 {
+		return "permanently"
+	}
+	return "for " + d.String()
+}
+
+// loadMutes는 DB에 저장된 유효한 음소거를 메모리로 불러옵니다.
+func (h *Hub) loadMutes() {
+	if h.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mutes, err := db.GetActiveMutes(ctx, h.db)
+	if err != nil {
+		h.logger.Error("[sshchat] failed to load mutes", "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	for _, m := range mutes {
+		var until time.Time
+		if m.ExpiresAt != nil {
+			until = *m.ExpiresAt
+		}
+		h.mutes[m.Target] = until
+	}
+	h.mu.Unlock()
+}
+
+// checkMuted는 username이 음소거 중이면 오류를 반환합니다. 만료된 음소거는 지웁니다.
+func (h *Hub) checkMuted(username string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	until, ok := h.mutes[username]
+	if !ok {
+		return nil
+	}
+	if until.IsZero() {
+		return fmt.Errorf("you are muted")
+	}
+	if time.Now().After(until) {
+		delete(h.mutes, username)
+		return nil
+	}
+	return fmt.Errorf("you are muted until %s", until.Format("2006-01-02 15:04:05"))
+}
+
+// roleOf는 username의 역할을 접속 중인 세션이나 DB에서 찾습니다.
+func (h *Hub) roleOf(username string) Role {
+	h.mu.RLock()
+	sessions := h.sessionsOfLocked(username)
+	h.mu.RUnlock()
+	if len(sessions) > 0 {
+		return sessions[0].Role()
+	}
+
+	if h.db == nil {
+		return RoleMember
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	user, err := db.GetUser(ctx, h.db, username)
+	if err != nil || user == nil {
+		return RoleMember
+	}
+	if h.isOwner(user.Fingerprint) {
+		return RoleOwner
+	}
+	role, _ := ParseRole(user.Role)
+	return role
+}
+
+// isOwner는 키 지문(SHA256:...)이 설정의 OWNERS에 들어있는지 확인합니다. 이 키로 접속한 사용자는 DB의 역할과 관계없이 owner입니다.
+// 사용자 이름은 먼저 접속한 키가 차지하므로 이름이 아닌 키로 확인해야 합니다.
+func (h *Hub) isOwner(fingerprint string) bool {
+	return h.config != nil && fingerprint != "" && slices.Contains(h.config.Owners, fingerprint)
+}
+
+// authorize는 actor가 need 이상의 권한을 가졌고, target(사용자 이름)보다 높은 역할인지 확인합니다.
+// target이 비어있으면 권한 수준만 확인합니다.
+func (h *Hub) authorize(actor *Client, need Role, target string) error {
+	if actor.Role() < need {
+		return fmt.Errorf("permission denied: requires %s", need)
+	}
+	if target == "" {
+		return nil
+	}
+	if target == actor.Username() {
+		return fmt.Errorf("cannot do that to yourself")
+	}
+	if h.roleOf(target) >= actor.Role() {
+		return fmt.Errorf("permission denied: %s is %s", target, h.roleOf(target))
+	}
+
+	return nil
+}
+
+// requireDB는 DB에 저장해야 하는 관리 명령에서 DB가 없으면 오류를 반환합니다.
+func (h *Hub) requireDB() error {
+	if h.db == nil {
+		return fmt.Errorf("moderation is not available without the database")
+	}
+	return nil
+}
+
+// audit은 관리 명령을 로그와 moderation_logs에 남깁니다.
+func (h *Hub) audit(actor *Client, action string, target string, reason string) {
+	h.logger.Info("[sshchat] moderation", "actor", actor.Username(), "action", action, "target", target, "reason", reason)
+	if h.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := &db.ModerationLog{
+		Actor:     actor.Username(),
+		Action:    action,
+		Target:    target,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if err := db.InsertModerationLog(ctx, h.db, row); err != nil {
+		h.logger.Error("[sshchat] failed to record moderation log", "actor", actor.Username(), "action", action, "error", err)
+	}
+}
+
+// disconnect는 match에 해당하는 모든 세션을 reason과 함께 끊고, 끊은 세션 수를 반환합니다.
+func (h *Hub) disconnect(match func(c *Client) bool, reason string) int {
+	h.mu.RLock()
+	targets := make([]*Client, 0)
+	for c := range h.clients {
+		if match(c) {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range targets {
+		c.Disconnect(reason)
+	}
+
+	return len(targets)
+}
+
+// Kick은 username의 모든 세션을 끊습니다. (moderator 이상)
+func (h *Hub) Kick(actor *Client, username string, reason string) error {
+	if err := h.authorize(actor, RoleModerator, username); err != nil {
+		return err
+	}
+
+	room := ""
+	n := h.disconnect(func(c *Client) bool {
+		if c.Username() == username {
+			room = c.Room()
+			return true
+		}
+		return false
+	}, fmt.Sprintf("You were kicked by %s. %s", actor.Username(), reason))
+	if n == 0 {
+		return fmt.Errorf("%s is not online", username)
+	}
+
+	h.audit(actor, "kick", targetUser+":"+username, reason)
+	if room != "" {
+		if reason != "" {
+			h.announce(room, "%s was kicked by %s (%s)", username, actor.Username(), reason)
+		} else {
+			h.announce(room, "%s was kicked by %s", username, actor.Username())
+		}
+	}
+
+	return nil
+}
+
+// Ban은 사용자 이름, IP, 공개키 지문 중 하나를 차단하고 해당하는 세션을 끊습니다. (moderator 이상)
+// d가 0이면 영구 차단입니다.
+func (h *Hub) Ban(actor *Client, kind string, target string, d time.Duration, reason string) error {
+	if err := h.requireDB(); err != nil {
+		return err
+	}
+	user := ""
+	if kind == targetUser {
+		user = target
+	}
+	if err := h.authorize(actor, RoleModerator, user); err != nil {
+		return err
+	}
+
+	match := func(c *Client) bool {
+		switch kind {
+		case targetIP:
+			return NormalizeIP(c.IP()) == target
+		case targetKey:
+			return c.Fingerprint() == target
+		default:
+			return c.Username() == target
+		}
+	}
+
+	// IP나 키로 차단할 때도 자신보다 높은 역할의 사용자를 끊을 수는 없습니다.
+	h.mu.RLock()
+	for c := range h.clients {
+		if match(c) && (c == actor || c.Role() >= actor.Role()) {
+			h.mu.RUnlock()
+			return fmt.Errorf("permission denied: %s %s matches %s", kind, target, c.Username())
+		}
+	}
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ban := &db.Sanction{
+		Action:     "ban",
+		TargetType: kind,
+		Target:     target,
+		Reason:     reason,
+		CreatedBy:  actor.Username(),
+		CreatedAt:  time.Now(),
+	}
+	if d > 0 {
+		expires := time.Now().Add(d)
+		ban.ExpiresAt = &expires
+	}
+	if err := db.InsertSanction(ctx, h.db, ban); err != nil {
+		h.logger.Error("[sshchat] failed to save ban", "actor", actor.Username(), "target", target, "error", err)
+		return fmt.Errorf("failed to save ban")
+	}
+
+	h.audit(actor, "ban", kind+":"+target, strings.TrimSpace(expiryText(d)+" "+reason))
+	h.disconnect(match, fmt.Sprintf("You were banned %s by %s. %s", expiryText(d), actor.Username(), reason))
+
+	return nil
+}
+
+// Unban은 대상에 걸린 차단을 해제합니다. (moderator 이상)
+func (h *Hub) Unban(actor *Client, kind string, target string) error {
+	if err := h.requireDB(); err != nil {
+		return err
+	}
+	if err := h.authorize(actor, RoleModerator, ""); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	n, err := db.LiftSanctions(ctx, h.db, "ban", kind, target)
+	if err != nil {
+		h.logger.Error("[sshchat] failed to lift ban", "actor", actor.Username(), "target", target, "error", err)
+		return fmt.Errorf("failed to lift ban")
+	}
+	if n == 0 {
+		return fmt.Errorf("%s %s is not banned", kind, target)
+	}
+
+	h.audit(actor, "unban", kind+":"+target, "")
+	return nil
+}
+
+// Mute는 username이 대화방 메시지와 개인 메시지를 보내지 못하게 합니다. (moderator 이상)
+func (h *Hub) Mute(actor *Client, username string, d time.Duration, reason string) error {
+	if err := h.authorize(actor, RoleModerator, username); err != nil {
+		return err
+	}
+
+	var until time.Time
+	mute := &db.Sanction{
+		Action:     "mute",
+		TargetType: targetUser,
+		Target:     username,
+		Reason:     reason,
+		CreatedBy:  actor.Username(),
+		CreatedAt:  time.Now(),
+	}
+	if d > 0 {
+		until = time.Now().Add(d)
+		mute.ExpiresAt = &until
+	}
+	if h.db != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		if err := db.InsertSanction(ctx, h.db, mute); err != nil {
+			h.logger.Error("[sshchat] failed to save mute", "actor", actor.Username(), "target", username, "error", err)
+			return fmt.Errorf("failed to save mute")
+		}
+	}
+
+	h.mu.Lock()
+	h.mutes[username] = until
+	sessions := h.sessionsOfLocked(username)
+	h.mu.Unlock()
+
+	h.audit(actor, "mute", targetUser+":"+username, strings.TrimSpace(expiryText(d)+" "+reason))
+	for _, c := range sessions {
+		c.systemMessage("you were muted %s by %s %s", expiryText(d), actor.Username(), reason)
+	}
+
+	return nil
+}
+
+// Unmute는 username의 음소거를 해제합니다. (moderator 이상)
+func (h *Hub) Unmute(actor *Client, username string) error {
+	if err := h.authorize(actor, RoleModerator, ""); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	_, muted := h.mutes[username]
+	delete(h.mutes, username)
+	sessions := h.sessionsOfLocked(username)
+	h.mu.Unlock()
+
+	if h.db != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		n, err := db.LiftSanctions(ctx, h.db, "mute", targetUser, username)
+		if err != nil {
+			h.logger.Error("[sshchat] failed to lift mute", "actor", actor.Username(), "target", username, "error", err)
+			return fmt.Errorf("failed to lift mute")
+		}
+		muted = muted || n > 0
+	}
+	if !muted {
+		return fmt.Errorf("%s is not muted", username)
+	}
+
+	h.audit(actor, "unmute", targetUser+":"+username, "")
+	for _, c := range sessions {
+		c.systemMessage("you were unmuted by %s", actor.Username())
+	}
+
+	return nil
+}
+
+// SetRole은 username의 역할을 바꿉니다. 자신보다 낮은 역할만 줄 수 있습니다. (admin 이상)
+func (h *Hub) SetRole(actor *Client, username string, role Role) error {
+	if err := h.requireDB(); err != nil {
+		return err
+	}
+	if err := h.authorize(actor, RoleAdmin, username); err != nil {
+		return err
+	}
+	if role >= actor.Role() {
+		return fmt.Errorf("permission denied: cannot grant %s", role)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ok, err := db.SetUserRole(ctx, h.db, username, role.String())
+	if err != nil {
+		h.logger.Error("[sshchat] failed to update role", "actor", actor.Username(), "target", username, "error", err)
+		return fmt.Errorf("failed to update role")
+	}
+	if !ok {
+		return fmt.Errorf("no such user: %s", username)
+	}
+
+	h.mu.RLock()
+	sessions := h.sessionsOfLocked(username)
+	h.mu.RUnlock()
+
+	h.audit(actor, "role", targetUser+":"+username, role.String())
+	for _, c := range sessions {
+		c.setRole(role)
+		c.systemMessage("%s made you %s", actor.Username(), role)
+	}
+
+	return nil
+}
+
+// ModerationLogs는 최근 관리 기록을 반환합니다. (admin 이상)
+func (h *Hub) ModerationLogs(actor *Client, limit int) ([]db.ModerationLog, error) {
+	if err := h.requireDB(); err != nil {
+		return nil, err
+	}
+	if err := h.authorize(actor, RoleAdmin, ""); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	logs, err := db.GetModerationLogs(ctx, h.db, limit)
+	if err != nil {
+		h.logger.Error("[sshchat] failed to load moderation logs", "actor", actor.Username(), "error", err)
+		return nil, fmt.Errorf("failed to load moderation logs")
+	}
+
+	return logs, nil
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:       "kick",
+		Usage:      "/kick <user> [reason]",
+		Help:       "disconnect a user (moderator)",
+		MaxArgs:    2,
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) == 0 {
+				return usageError(commands["kick"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			reason := ""
+			if len(args) == 2 {
+				reason = args[1]
+			}
+			if err := hub.Kick(c, args[0], reason); err != nil {
+				return err
+			}
+			c.systemMessage("kicked %s", args[0])
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "ban",
+		Usage:      "/ban <user|ip|key> [duration] [reason]",
+		Help:       "ban a user, IP address or key fingerprint, e.g. /ban bob 7d spam (moderator)",
+		MaxArgs:    2,
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) == 0 {
+				return usageError(commands["ban"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			kind, target, err := parseTarget(args[0])
+			if err != nil {
+				return err
+			}
+			var d time.Duration
+			reason := ""
+			if len(args) == 2 {
+				d, reason = splitDurationReason(args[1])
+			}
+			if err := hub.Ban(c, kind, target, d, reason); err != nil {
+				return err
+			}
+			c.systemMessage("banned %s %s %s", kind, target, expiryText(d))
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "unban",
+		Usage:      "/unban <user|ip|key>",
+		Help:       "lift a ban (moderator)",
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) != 1 {
+				return usageError(commands["unban"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			kind, target, err := parseTarget(args[0])
+			if err != nil {
+				return err
+			}
+			if err := hub.Unban(c, kind, target); err != nil {
+				return err
+			}
+			c.systemMessage("unbanned %s %s", kind, target)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "mute",
+		Usage:      "/mute <user> [duration] [reason]",
+		Help:       "stop a user from sending messages (moderator)",
+		MaxArgs:    2,
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) == 0 {
+				return usageError(commands["mute"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			var d time.Duration
+			reason := ""
+			if len(args) == 2 {
+				d, reason = splitDurationReason(args[1])
+			}
+			if err := hub.Mute(c, args[0], d, reason); err != nil {
+				return err
+			}
+			c.systemMessage("muted %s %s", args[0], expiryText(d))
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "unmute",
+		Usage:      "/unmute <user>",
+		Help:       "let a muted user send messages again (moderator)",
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) != 1 {
+				return usageError(commands["unmute"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			if err := hub.Unmute(c, args[0]); err != nil {
+				return err
+			}
+			c.systemMessage("unmuted %s", args[0])
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "op",
+		Usage:      "/op <user> [moderator|admin]",
+		Help:       "give a user a role, moderator by default (admin)",
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) == 0 || len(args) > 2 {
+				return usageError(commands["op"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			role := RoleModerator
+			if len(args) == 2 {
+				if role, err = ParseRole(args[1]); err != nil {
+					return err
+				}
+			}
+			if err := hub.SetRole(c, args[0], role); err != nil {
+				return err
+			}
+			c.systemMessage("%s is now %s", args[0], role)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "deop",
+		Usage:      "/deop <user>",
+		Help:       "make a user a regular member again (admin)",
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			if len(args) != 1 {
+				return usageError(commands["deop"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			if err := hub.SetRole(c, args[0], RoleMember); err != nil {
+				return err
+			}
+			c.systemMessage("%s is now %s", args[0], RoleMember)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "modlog",
+		Usage:      "/modlog [count]",
+		Help:       "review recent moderation actions (admin)",
+		Background: true,
+		Run: func(c *Client, args []string) error {
+			limit := 20
+			switch len(args) {
+			case 0:
+			case 1:
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return usageError(commands["modlog"])
+				}
+				limit = min(n, 200)
+			default:
+				return usageError(commands["modlog"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			logs, err := hub.ModerationLogs(c, limit)
+			if err != nil {
+				return err
+			}
+			if len(logs) == 0 {
+				c.systemMessage("no moderation actions recorded")
+				return nil
+			}
+
+			c.mu.Lock()
+			ck := c.clock
+			c.mu.Unlock()
+
+			lines := make([]string, 0, len(logs)+1)
+			lines = append(lines, fmt.Sprintf("%d recent moderation actions:", len(logs)))
+			for _, l := range logs {
+				lines = append(lines, strings.TrimRight(fmt.Sprintf("  [%s] %s %s %s %s", ck.format(l.CreatedAt), l.Actor, l.Action, l.Target, l.Reason), " "))
+			}
+			c.systemMessage("%s", strings.Join(lines, "\n"))
+			return nil
+		},
+	})
+}
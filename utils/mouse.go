@@ -0,0 +1,54 @@
+package utils
+
+import "strings"
+
+// setMouse는 마우스 휠 스크롤을 켜거나 끕니다. 켜져 있는 동안 글자를 선택하려면 Shift를 누른 채 드래그해야 합니다.
+func (c *Client) setMouse(on bool) {
+	c.mu.Lock()
+	c.mouse = on
+	c.mu.Unlock()
+
+	if c.session == nil {
+		return
+	}
+	if on {
+		c.screen.send(c.session, mouseOn)
+	} else {
+		c.screen.send(c.session, mouseOff)
+	}
+}
+
+func (c *Client) mouseEnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mouse
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "mouse",
+		Usage: "/mouse [on|off]",
+		Help:  "scroll with the mouse wheel; while on, hold Shift to select text",
+		Run: func(c *Client, args []string) error {
+			var on bool
+			switch {
+			case len(args) == 0:
+				on = !c.mouseEnabled()
+			case len(args) == 1 && strings.EqualFold(args[0], "on"):
+				on = true
+			case len(args) == 1 && strings.EqualFold(args[0], "off"):
+				on = false
+			default:
+				return usageError(commands["mouse"])
+			}
+
+			c.setMouse(on)
+			if on {
+				c.systemMessage("mouse on: scroll with the wheel, hold Shift to select text")
+			} else {
+				c.systemMessage("mouse off")
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// containsAddr는 addr가 prefixes 중 하나에 속하는지 확인합니다.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) (netip.Prefix, bool) {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return prefix, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// CheckAddr는 GeoIP보다 먼저 접속 주소를 IP 목록과 비교합니다.
+// IPDenylist에 있으면 거부하고, 루프백이나 TrustedNetworks에 있으면 trusted로 받아 국가 제한을 건너뜁니다.
+// IPAllowlist가 비어있지 않으면 목록에 없는 주소는 거부합니다. 신뢰하는 네트워크는 허용 목록에 있는 것으로 봅니다.
+func (cfg *Config) CheckAddr(addr netip.Addr) (trusted bool, err error) {
+	addr = addr.Unmap().WithZone("")
+
+	if prefix, ok := containsAddr(cfg.IPDenylist, addr); ok {
+		return false, fmt.Errorf("address %s is in denied network %s", addr, prefix)
+	}
+	if addr.IsLoopback() {
+		return true, nil
+	}
+	if _, ok := containsAddr(cfg.TrustedNetworks, addr); ok {
+		return true, nil
+	}
+	if len(cfg.IPAllowlist) > 0 {
+		if _, ok := containsAddr(cfg.IPAllowlist, addr); !ok {
+			return false, fmt.Errorf("address %s is not in the allowlist", addr)
+		}
+	}
+	return false, nil
+}
+
+// envPrefixes는 "10.0.0.0/8, 192.168.1.5, fd00::/8" 같은 환경 변수를 네트워크 목록으로 읽습니다.
+// 주소만 적으면 그 주소 하나를 뜻합니다. 잘못된 항목은 경고를 남기고 건너뜁니다.
+func envPrefixes(name string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0)
+	for _, item := range splitList(os.Getenv(name)) {
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q: %v", s, err)
+		}
+		// ::ffff:10.0.0.0/104 같은 IPv4-mapped 대역은 IPv4 대역으로 바꿉니다.
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q: %v", s, err)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func mustPrefixes(t *testing.T, items ...string) []netip.Prefix {
+	t.Helper()
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			t.Fatalf("parsePrefix(%q): %v", item, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       []string
+		deny        []string
+		trusted     []string
+		addr        string
+		wantTrusted bool
+		wantErr     bool
+	}{
+		{name: "empty allowlist allows all", addr: "203.0.113.7"},
+		{name: "allowlist admits member", allow: []string{"203.0.113.0/24"}, addr: "203.0.113.7"},
+		{name: "allowlist rejects others", allow: []string{"203.0.113.0/24"}, addr: "198.51.100.1", wantErr: true},
+		{name: "deny beats allow", allow: []string{"10.0.0.0/8"}, deny: []string{"10.9.0.0/16"}, addr: "10.9.1.1", wantErr: true},
+		{name: "deny beats trusted", deny: []string{"10.9.0.0/16"}, trusted: []string{"10.0.0.0/8"}, addr: "10.9.1.1", wantErr: true},
+		{name: "ipv4 loopback trusted", allow: []string{"203.0.113.0/24"}, addr: "127.0.0.1", wantTrusted: true},
+		{name: "ipv6 loopback trusted", addr: "::1", wantTrusted: true},
+		{name: "4in6 loopback trusted", addr: "::ffff:127.0.0.1", wantTrusted: true},
+		{name: "4in6 matches ipv4 prefix", trusted: []string{"172.17.0.0/16"}, addr: "::ffff:172.17.0.2", wantTrusted: true},
+		{name: "4in6 matches ipv4 deny", deny: []string{"198.51.100.0/24"}, addr: "::ffff:198.51.100.9", wantErr: true},
+		{name: "4in6 prefix matches ipv4", trusted: []string{"::ffff:10.0.0.0/104"}, addr: "10.1.2.3", wantTrusted: true},
+		{name: "bare ip matches only itself", deny: []string{"192.0.2.5"}, addr: "192.0.2.5", wantErr: true},
+		{name: "bare ip does not match neighbour", deny: []string{"192.0.2.5"}, addr: "192.0.2.6"},
+		{name: "trusted counts as allowed", allow: []string{"203.0.113.0/24"}, trusted: []string{"10.0.0.0/8"}, addr: "10.1.1.1", wantTrusted: true},
+		{name: "127 text prefix is not loopback", allow: []string{"203.0.113.0/24"}, addr: "1270::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				IPAllowlist:     mustPrefixes(t, tt.allow...),
+				IPDenylist:      mustPrefixes(t, tt.deny...),
+				TrustedNetworks: mustPrefixes(t, tt.trusted...),
+			}
+			trusted, err := cfg.CheckAddr(netip.MustParseAddr(tt.addr))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckAddr(%s) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			}
+			if trusted != tt.wantTrusted {
+				t.Fatalf("CheckAddr(%s) trusted = %v, want %v", tt.addr, trusted, tt.wantTrusted)
+			}
+		})
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{in: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{in: "192.0.2.5", want: "192.0.2.5/32"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "::ffff:192.0.2.5", want: "192.0.2.5/32"},
+		{in: "::ffff:10.0.0.0/104", want: "10.0.0.0/8"},
+		{in: "fd00::/8", want: "fd00::/8"},
+		{in: "10.0.0.0/33", wantErr: true},
+		{in: "not-an-ip", wantErr: true},
+		{in: "10.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parsePrefix(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrefix(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Fatalf("parsePrefix(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvPrefixes(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want []string
+	}{
+		{name: "empty", env: "", want: []string{}},
+		{name: "spaces and empty items", env: " 10.0.0.0/8 , ,192.0.2.5 ", want: []string{"10.0.0.0/8", "192.0.2.5/32"}},
+		{name: "invalid entries skipped", env: "10.0.0.0/8,bogus,300.1.1.1,fd00::/8", want: []string{"10.0.0.0/8", "fd00::/8"}},
+		{name: "all invalid", env: "bogus", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_PREFIXES", tt.env)
+			got := make([]string, 0)
+			for _, prefix := range envPrefixes("TEST_PREFIXES") {
+				got = append(got, prefix.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("envPrefixes(%q) = %q, want %q", tt.env, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// announce는 room에 있는 모든 Client에게 [system] 입퇴장 알림을 보냅니다. 알림은 DB에 저장하지 않습니다.
+func (h *Hub) announce(room string, format string, args ...any) {
+	msg := Message{
+		Room:      room,
+		Timestamp: time.Now(),
+		Username:  "system",
+		Content:   fmt.Sprintf(format, args...),
+		System:    true,
+	}
+
+	h.mu.RLock()
+	clients := make([]*Client, 0)
+	if r, ok := h.rooms[room]; ok {
+		for c := range r.clients {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range clients {
+		c.receive(msg)
+	}
+}
+
+// idleAfter는 사이드바에 유휴 표시를 붙이기 시작하는 시간입니다.
+const idleAfter = 5 * time.Minute
+
+// Presence는 /who와 사이드바에 표시되는 접속자 한 명의 정보입니다. 여러 세션으로 접속했으면 하나로 합칩니다.
+type Presence struct {
+	Username string
+	Rooms    []string
+	Idle     time.Duration // 가장 최근에 입력한 세션 기준
+	Away     string        // /away로 남긴 메시지. 자리에 있으면 빈 문자열
+	Sessions int
+	Country  string
+}
+
+// Who는 접속 중인 사용자를 이름 순으로 반환합니다.
+func (h *Hub) Who() []Presence {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	return collectPresence(clients)
+}
+
+// RoomPresence는 name 대화방에 있는 사용자를 이름 순으로 반환합니다.
+func (h *Hub) RoomPresence(name string) []Presence {
+	h.mu.RLock()
+	clients := make([]*Client, 0)
+	if room, ok := h.rooms[name]; ok {
+		for c := range room.clients {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	return collectPresence(clients)
+}
+
+// collectPresence는 세션 목록을 사용자별로 합칩니다.
+func collectPresence(clients []*Client) []Presence {
+	byName := make(map[string]*Presence)
+	for _, c := range clients {
+		p, ok := byName[c.Username()]
+		if !ok {
+			p = &Presence{Username: c.Username(), Rooms: make([]string, 0), Idle: c.Idle()}
+			byName[c.Username()] = p
+		}
+		p.Sessions++
+		p.Idle = min(p.Idle, c.Idle())
+		if away := c.Away(); away != "" {
+			p.Away = away
+		}
+		if room := c.Room(); room != "" && !slices.Contains(p.Rooms, room) {
+			p.Rooms = append(p.Rooms, room)
+		}
+		if info := c.IPInfo(); info != nil && p.Country == "" {
+			p.Country = info.Country
+		}
+	}
+
+	who := make([]Presence, 0, len(byName))
+	for _, p := range byName {
+		sort.Strings(p.Rooms)
+		who = append(who, *p)
+	}
+	sort.Slice(who, func(i, j int) bool { return who[i].Username < who[j].Username })
+
+	return who
+}
+
+// formatIdle은 유휴 시간을 "3m", "2h5m", "1d4h"처럼 짧게 표시합니다. 1분 미만이면 "active"입니다.
+func formatIdle(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "active"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	default:
+		return fmt.Sprintf("%dd%dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+	}
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "who",
+		Usage: "/who",
+		Help:  "list connected users with idle time (and country for admins)",
+		Run: func(c *Client, args []string) error {
+			if len(args) != 0 {
+				return usageError(commands["who"])
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+
+			admin := c.Role() >= RoleAdmin
+			who := hub.Who()
+			lines := make([]string, 0, len(who)+1)
+			lines = append(lines, fmt.Sprintf("%d users online:", len(who)))
+			for _, p := range who {
+				rooms := make([]string, 0, len(p.Rooms))
+				for _, room := range p.Rooms {
+					rooms = append(rooms, "#"+room)
+				}
+
+				line := fmt.Sprintf("  %s  %s  %s", p.Username, strings.Join(rooms, ","), formatIdle(p.Idle))
+				if p.Away != "" {
+					line += fmt.Sprintf("  (away: %s)", p.Away)
+				}
+				if p.Sessions > 1 {
+					line += fmt.Sprintf("  (%d sessions)", p.Sessions)
+				}
+				if admin && p.Country != "" {
+					line += "  " + p.Country
+				}
+				lines = append(lines, line)
+			}
+			c.systemMessage("%s", strings.Join(lines, "\n"))
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:    "away",
+		Usage:   "/away [message]",
+		Help:    "mark yourself as away, or back again when already away",
+		MaxArgs: 1,
+		Run: func(c *Client, args []string) error {
+			if len(args) == 0 && c.Away() != "" {
+				c.setAway("")
+				c.systemMessage("you are back")
+				return nil
+			}
+
+			message := "away"
+			if len(args) == 1 {
+				message = args[0]
+			}
+			c.setAway(message)
+			c.systemMessage("you are marked as away: %s (/away again to come back)", message)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sshchat/db"
+)
+
+// loadProfile은 저장된 사용자 설정과 역할을 Client에 적용합니다. 등록되지 않은 사용자는 기본값을 그대로 사용합니다.
+// DB를 읽지 못하면 역할을 알 수 없으므로 오류를 반환합니다.
+func (h *Hub) loadProfile(c *Client) error {
+	// OWNERS는 키 지문으로 확인하므로 DB 없이도, DB에 저장된 역할보다 우선해서 적용합니다.
+	if h.isOwner(c.Fingerprint()) {
+		defer c.setRole(RoleOwner)
+	}
+	if h.db == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	user, err := db.GetUser(ctx, h.db, c.Username())
+	if err != nil {
+		h.logger.Error("[sshchat] failed to load profile", "user", c.Username(), "error", err)
+		return fmt.Errorf("failed to load your profile, try again later")
+	}
+	if user == nil {
+		return nil
+	}
+
+	role, err := ParseRole(user.Role)
+	if err != nil {
+		h.logger.Error("[sshchat] invalid role", "user", c.Username(), "role", user.Role)
+	}
+	c.setRole(role)
+
+	if user.Theme != "" {
+		c.setTheme(user.Theme)
+	}
+	if user.Timezone != "" {
+		c.setTimezone(user.Timezone)
+	}
+	if user.TimeFormat != "" {
+		c.setTimeFormat(user.TimeFormat)
+	}
+	if _, ok := notifyModes[user.Notify]; ok {
+		c.setNotify(user.Notify)
+	}
+
+	return nil
+}
+
+// updateProfile은 save로 프로필 값 하나를 쓰기 대기열에서 저장합니다. 저장에 실패하면 c에게 알립니다.
+// DB가 없으면 이번 접속에만 적용되도록 아무것도 하지 않습니다.
+func (h *Hub) updateProfile(c *Client, field string, save func(ctx context.Context) error) error {
+	if h.db == nil {
+		return nil
+	}
+
+	return h.enqueue(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		if err := save(ctx); err != nil {
+			h.logger.Error("[sshchat] failed to save profile", "user", c.Username(), "field", field, "error", err)
+			c.systemMessage("failed to save %s", field)
+		}
+	})
+}
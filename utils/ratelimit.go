@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// floodForgiveAfter 동안 제한을 넘지 않으면 경고와 음소거 횟수를 잊습니다.
+	floodForgiveAfter = 10 * time.Minute
+
+	// maxLimiterEntries를 넘으면 가득 찬(한동안 쓰지 않은) 버킷을 정리합니다.
+	maxLimiterEntries = 4096
+)
+
+// tokenBucket은 초당 rate개씩 채워지고 최대 burst개까지 쌓이는 토큰 버킷입니다.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill은 마지막으로 확인한 뒤 지난 시간만큼 토큰을 채웁니다.
+func (b *tokenBucket) refill(now time.Time, rate float64, burst int) {
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
+
+// take는 토큰 하나를 쓸 수 있으면 쓰고 true를 반환합니다.
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) bool {
+	b.refill(now, rate, burst)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// floodRecord는 사용자가 제한을 넘은 기록입니다.
+type floodRecord struct {
+	warnings int
+	mutes    int
+	last     time.Time
+}
+
+// floodPenalty는 제한을 넘었을 때 내리는 조치입니다.
+type floodPenalty int
+
+const (
+	floodAllow floodPenalty = iota
+	floodWarn
+	floodMute
+	floodDisconnect
+)
+
+func (p floodPenalty) String() string {
+	switch p {
+	case floodWarn:
+		return "warn"
+	case floodMute:
+		return "mute"
+	case floodDisconnect:
+		return "disconnect"
+	default:
+		return "allow"
+	}
+}
+
+// rateLimiter는 사용자별, IP별 토큰 버킷과 도배 기록을 관리합니다.
+type rateLimiter struct {
+	mu      sync.Mutex
+	config  *Config
+	users   map[string]*tokenBucket
+	ips     map[string]*tokenBucket
+	records map[string]*floodRecord
+}
+
+func newRateLimiter(config *Config) *rateLimiter {
+	return &rateLimiter{
+		config:  config,
+		users:   make(map[string]*tokenBucket),
+		ips:     make(map[string]*tokenBucket),
+		records: make(map[string]*floodRecord),
+	}
+}
+
+// bucket은 key의 버킷을 찾거나, 없으면 가득 찬 버킷을 새로 만듭니다.
+func bucket(buckets map[string]*tokenBucket, key string, now time.Time, rate float64, burst int) *tokenBucket {
+	b, ok := buckets[key]
+	if !ok {
+		if len(buckets) >= maxLimiterEntries {
+			for k, old := range buckets {
+				if old.refill(now, rate, burst); old.tokens >= float64(burst) {
+					delete(buckets, k)
+				}
+			}
+		}
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		buckets[key] = b
+	}
+	return b
+}
+
+// check는 username과 ip로 메시지 하나를 보내도 되는지 확인하고, 넘었다면 내릴 조치를 반환합니다.
+// 사용자와 IP 버킷 중 하나라도 비어있으면 제한을 넘은 것으로 봅니다.
+func (l *rateLimiter) check(username string, ip string, now time.Time) floodPenalty {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cfg := l.config
+	allowed := true
+	if cfg.UserRate > 0 {
+		allowed = bucket(l.users, username, now, cfg.UserRate, cfg.UserBurst).take(now, cfg.UserRate, cfg.UserBurst)
+	}
+	if allowed && cfg.IPRate > 0 {
+		allowed = bucket(l.ips, ip, now, cfg.IPRate, cfg.IPBurst).take(now, cfg.IPRate, cfg.IPBurst)
+	}
+	if allowed {
+		return floodAllow
+	}
+
+	record, ok := l.records[username]
+	if !ok || now.Sub(record.last) > floodForgiveAfter {
+		record = &floodRecord{}
+		l.records[username] = record
+	}
+	record.last = now
+
+	if record.warnings < cfg.FloodWarnings {
+		record.warnings++
+		return floodWarn
+	}
+	if record.mutes < cfg.FloodMaxMutes {
+		record.warnings = 0
+		record.mutes++
+		return floodMute
+	}
+
+	delete(l.records, username)
+	return floodDisconnect
+}
+
+// allowMessage는 c가 메시지나 명령을 하나 보내도 되는지 확인합니다.
+// 제한을 넘으면 경고, 일시 음소거, 접속 종료 순서로 조치하고 false를 반환합니다.
+func (h *Hub) allowMessage(c *Client) bool {
+	penalty := h.limiter.check(c.Username(), NormalizeIP(c.IP()), time.Now())
+	switch penalty {
+	case floodAllow:
+		return true
+	case floodWarn:
+		c.systemMessage("you are sending messages too fast, slow down")
+	case floodMute:
+		until := time.Now().Add(h.config.FloodMute)
+		h.mu.Lock()
+		if current, ok := h.mutes[c.Username()]; !ok || (!current.IsZero() && current.Before(until)) {
+			h.mutes[c.Username()] = until
+		}
+		h.mu.Unlock()
+		c.systemMessage("you are muted for %s for flooding", h.config.FloodMute)
+	case floodDisconnect:
+		c.Disconnect("You were disconnected for flooding.")
+	}
+
+	h.logger.Info("[sshchat] flood", "user", c.Username(), "remote", c.IP(), "penalty", penalty.String())
+	return false
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &tokenBucket{tokens: 3, last: start}
+
+	// 가득 찬 버킷은 burst개까지 연속으로 보낼 수 있습니다.
+	for i := 0; i < 3; i++ {
+		if !b.take(start, 1, 3) {
+			t.Fatalf("take %d refused, want allowed within burst", i)
+		}
+	}
+	if b.take(start, 1, 3) {
+		t.Fatalf("take allowed after burst exhausted")
+	}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "half a token", elapsed: 500 * time.Millisecond, want: false},
+		{name: "one token", elapsed: time.Second, want: true},
+		{name: "spent again", elapsed: time.Second, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.take(start.Add(tt.elapsed), 1, 3); got != tt.want {
+				t.Fatalf("take after %s = %v, want %v", tt.elapsed, got, tt.want)
+			}
+		})
+	}
+
+	// 오래 쉬어도 burst개보다 많이 쌓이지 않습니다.
+	b.refill(start.Add(time.Hour), 1, 3)
+	if b.tokens != 3 {
+		t.Fatalf("tokens after long idle = %v, want 3", b.tokens)
+	}
+}
+
+func TestRateLimiterBuckets(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		config Config
+		sends  []struct{ user, ip string }
+		want   []bool // floodAllow인지
+	}{
+		{
+			name:   "user burst exhausted",
+			config: Config{UserRate: 1, UserBurst: 2, FloodWarnings: 10},
+			sends:  []struct{ user, ip string }{{"alice", "a"}, {"alice", "a"}, {"alice", "a"}, {"bob", "a"}},
+			want:   []bool{true, true, false, true},
+		},
+		{
+			name:   "ip bucket shared across usernames",
+			config: Config{IPRate: 1, IPBurst: 2, FloodWarnings: 10},
+			sends:  []struct{ user, ip string }{{"alice", "a"}, {"bob", "a"}, {"carol", "a"}, {"carol", "b"}},
+			want:   []bool{true, true, false, true},
+		},
+		{
+			name:   "zero rate disables limit",
+			config: Config{UserBurst: 1, IPBurst: 1},
+			sends:  []struct{ user, ip string }{{"alice", "a"}, {"alice", "a"}, {"alice", "a"}},
+			want:   []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(&tt.config)
+			for i, send := range tt.sends {
+				got := l.check(send.user, send.ip, now) == floodAllow
+				if got != tt.want[i] {
+					t.Fatalf("send %d (%s@%s) allowed = %v, want %v", i, send.user, send.ip, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(&Config{UserRate: 2, UserBurst: 1, FloodWarnings: 10})
+
+	if got := l.check("alice", "a", now); got != floodAllow {
+		t.Fatalf("first send = %s, want allow", got)
+	}
+	if got := l.check("alice", "a", now.Add(100*time.Millisecond)); got == floodAllow {
+		t.Fatalf("send before refill allowed")
+	}
+	if got := l.check("alice", "a", now.Add(600*time.Millisecond)); got != floodAllow {
+		t.Fatalf("send after refill = %s, want allow", got)
+	}
+}
+
+func TestRateLimiterEscalation(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(&Config{UserRate: 0.001, UserBurst: 1, FloodWarnings: 2, FloodMaxMutes: 1})
+
+	want := []floodPenalty{
+		floodAllow,
+		floodWarn, floodWarn, floodMute,
+		// 음소거하면 경고 횟수는 처음부터 다시 셉니다.
+		floodWarn, floodWarn, floodDisconnect,
+		// 접속을 끊으면 기록을 지웁니다.
+		floodWarn,
+	}
+	for i, w := range want {
+		if got := l.check("alice", "a", now); got != w {
+			t.Fatalf("check %d = %s, want %s", i, got, w)
+		}
+	}
+
+	// floodForgiveAfter 동안 조용하면 기록을 잊고 경고부터 다시 시작합니다.
+	later := now.Add(floodForgiveAfter + time.Second)
+	if got := l.check("alice", "a", later); got != floodWarn {
+		t.Fatalf("check after forgiveness = %s, want warn", got)
+	}
+	if rec := l.records["alice"]; rec.warnings != 1 || rec.mutes != 0 {
+		t.Fatalf("record after forgiveness = %+v, want 1 warning and no mutes", *rec)
+	}
+}
+
+func TestEnvBurstRejectsZero(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{env: "", want: 5},
+		{env: "3", want: 3},
+		{env: "0", want: 5},
+		{env: "-1", want: 5},
+		{env: "many", want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("TEST_BURST", tt.env)
+			if got := envBurst("TEST_BURST", 5); got != tt.want {
+				t.Fatalf("envBurst(%q) = %d, want %d", tt.env, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// terminalSetup은 접속 직후 보내는 시퀀스입니다.
+	// 대체 화면 버퍼로 전환하고 bracketed paste를 켭니다.
+	terminalSetup = "\x1b[?1049h\x1b[?2004h"
+
+	// mouseOn은 SGR 마우스 보고를 켭니다. 켜져 있으면 대부분의 터미널에서 드래그로 글자를 선택할 수 없어서
+	// /mouse로 켠 사용자에게만 보냅니다.
+	mouseOn  = "\x1b[?1000h\x1b[?1006h"
+	mouseOff = "\x1b[?1006l\x1b[?1000l"
+
+	// terminalRestore는 세션을 닫기 전에 terminalSetup을 되돌립니다.
+	// 색상을 초기화하고 커서를 다시 보이게 한 뒤 원래 화면으로 돌아갑니다.
+	terminalRestore = "\x1b[?1006l\x1b[?1000l\x1b[?2004l\x1b[0m\x1b[?25h\x1b[?1049l"
+)
+
+// screen은 클라이언트 터미널에 마지막으로 그린 화면을 기억해 두고,
+// 다음 프레임에서 바뀐 줄만 다시 그립니다.
+type screen struct {
+	mu     sync.Mutex
+	width  int
+	height int
+	lines  []string
+	valid  bool
+	closed bool // restore 이후에는 더 이상 그리지 않습니다.
+}
+
+// setup은 터미널을 채팅 화면용으로 전환합니다.
+func (sc *screen) setup(out io.Writer) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	_, _ = io.WriteString(out, terminalSetup)
+}
+
+// restore는 터미널을 접속 전 상태로 되돌립니다. 여러 번 호출해도 한 번만 보냅니다.
+func (sc *screen) restore(out io.Writer) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return
+	}
+	sc.closed = true
+	_, _ = io.WriteString(out, terminalRestore)
+}
+
+// send는 화면을 그리는 중간에 섞이지 않도록 seq를 따로 보냅니다. (알림 등)
+func (sc *screen) send(out io.Writer, seq string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return
+	}
+	_, _ = io.WriteString(out, seq)
+}
+
+// draw는 frame(행마다 한 줄, 각 줄은 w칸 이하)을 out에 그리고 커서를 (row, col)에 둡니다.
+// row, col은 1부터 시작합니다. 출력은 한 번의 Write로 보내서 패킷 수를 줄입니다.
+func (sc *screen) draw(out io.Writer, w int, h int, frame []string, row int, col int) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return nil
+	}
+
+	// 그리는 동안 커서가 화면 여기저기로 움직이는 것이 보이지 않도록 숨깁니다.
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[?25l")
+
+	full := !sc.valid || sc.width != w || sc.height != h
+	if full {
+		// 크기가 바뀌었거나 처음 그리는 경우에만 화면 전체를 지웁니다.
+		buf.WriteString("\x1b[2J")
+		sc.lines = make([]string, h)
+	}
+
+	for i := 0; i < h; i++ {
+		line := ""
+		if i < len(frame) {
+			line = frame[i]
+		}
+		if !full && sc.lines[i] == line {
+			continue
+		}
+
+		// 줄 내용을 쓰고 이전 내용이 남지 않도록 줄 끝까지 지웁니다.
+		fmt.Fprintf(&buf, "\x1b[%dH%s\x1b[K", i+1, line)
+		sc.lines[i] = line
+	}
+	fmt.Fprintf(&buf, "\x1b[%d;%dH\x1b[?25h", row, col)
+
+	sc.width = w
+	sc.height = h
+	sc.valid = true
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
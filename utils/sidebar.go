@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// sidebarWidth는 구분선을 포함한 사이드바의 칸 수입니다.
+	sidebarWidth = 20
+
+	// minSidebarTermWidth보다 좁은 터미널에서는 메시지 영역을 위해 사이드바를 숨깁니다.
+	minSidebarTermWidth = 60
+)
+
+// sidebarLines는 room에 접속한 사용자 목록을 최대 rows줄로 만듭니다. 각 줄은 sidebarWidth-2칸 이하입니다.
+// 이름 앞의 표시는 "-" 자리 비움(/away), "~" 유휴 상태입니다.
+func sidebarLines(hub *Hub, room string, rows int, p painter) []string {
+	if hub == nil || rows <= 0 {
+		return []string{}
+	}
+
+	inner := sidebarWidth - 2
+	members := hub.RoomPresence(room)
+	lines := make([]string, 0, rows)
+	lines = append(lines, p.paint(truncateWidth(fmt.Sprintf("#%s (%d)", room, len(members)), inner), p.theme.System))
+
+	for i, m := range members {
+		if len(lines) == rows-1 && i < len(members)-1 {
+			lines = append(lines, p.paint(fmt.Sprintf("+%d more", len(members)-i), p.theme.Timestamp))
+			break
+		}
+		if len(lines) == rows {
+			break
+		}
+
+		marker := " "
+		switch {
+		case m.Away != "":
+			marker = "-"
+		case m.Idle >= idleAfter:
+			marker = "~"
+		}
+		name := truncateWidth(m.Username, inner-2)
+		if m.Away != "" || m.Idle >= idleAfter {
+			lines = append(lines, p.paint(marker+" "+name, p.theme.Timestamp))
+		} else {
+			lines = append(lines, marker+" "+p.nick(name))
+		}
+	}
+
+	return lines
+}
+
+// withSidebar는 메시지 영역의 한 줄 뒤에 구분선과 사이드바 한 줄을 붙입니다.
+func withSidebar(line string, mainW int, side string, p painter) string {
+	pad := max(0, mainW-visibleWidth(line))
+	return line + strings.Repeat(" ", pad) + p.paint("|", p.theme.Timestamp) + " " + side
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "sidebar",
+		Usage: "/sidebar [on|off]",
+		Help:  "show or hide the list of users in the room (also Ctrl+B)",
+		Run: func(c *Client, args []string) error {
+			var show bool
+			switch {
+			case len(args) == 0:
+				show = !c.sidebarShown()
+			case len(args) == 1 && strings.EqualFold(args[0], "on"):
+				show = true
+			case len(args) == 1 && strings.EqualFold(args[0], "off"):
+				show = false
+			default:
+				return usageError(commands["sidebar"])
+			}
+
+			c.setSidebar(show)
+			if show {
+				if w, _ := c.Size(); w < minSidebarTermWidth {
+					c.systemMessage("sidebar on (hidden until the terminal is at least %d columns wide)", minSidebarTermWidth)
+					return nil
+				}
+				c.systemMessage("sidebar on")
+			} else {
+				c.systemMessage("sidebar off")
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"sshchat/db"
+)
+
+// DefaultTheme은 프로필에 테마가 없을 때 사용하는 테마입니다.
+const DefaultTheme = "dark"
+
+// colorLevel은 PTY 요청의 TERM으로 추정한 터미널의 색상 지원 수준입니다.
+type colorLevel int
+
+const (
+	colorNone colorLevel = iota // SGR을 전혀 보내지 않습니다. (dumb)
+	colorAttr                   // 굵게, 흐리게 같은 속성만 사용합니다. (vt100 등)
+	color16                     // 기본 16색
+	color256                    // 256색
+)
+
+// colorLevelOf는 TERM 값으로 색상 지원 수준을 정합니다. 알 수 없는 터미널은 16색으로 봅니다.
+func colorLevelOf(term string) colorLevel {
+	term = strings.ToLower(term)
+	switch {
+	case term == "" || term == "dumb":
+		return colorNone
+	case strings.HasPrefix(term, "vt"):
+		return colorAttr
+	case strings.Contains(term, "256color"), strings.Contains(term, "truecolor"),
+		strings.Contains(term, "direct"), strings.Contains(term, "kitty"), strings.Contains(term, "alacritty"):
+		return color256
+	default:
+		return color16
+	}
+}
+
+// style은 SGR 파라미터 묶음입니다. 색이 없는 터미널에서는 attr만 사용합니다.
+type style struct {
+	attr string // 예: "1" 굵게, "2" 흐리게
+	c16  string // 예: "36"
+	c256 string // 예: "38;5;39"
+}
+
+// sgr은 level에서 사용할 SGR 파라미터를 반환합니다. 보낼 것이 없으면 빈 문자열입니다.
+func (st style) sgr(level colorLevel) string {
+	params := make([]string, 0, 2)
+	if st.attr != "" && level >= colorAttr {
+		params = append(params, st.attr)
+	}
+	switch {
+	case level >= color256 && st.c256 != "":
+		params = append(params, st.c256)
+	case level >= color16 && st.c16 != "":
+		params = append(params, st.c16)
+	}
+
+	return strings.Join(params, ";")
+}
+
+// Theme은 메시지를 그릴 때 사용하는 색 구성입니다.
+type Theme struct {
+	Name      string
+	Timestamp style
+	System    style
+	Self      style // 자기 자신의 이름
+	Indicator style // 스크롤 표시줄
+	Mention   style // 나를 부른 "@이름"
+	Nicks     []style
+}
+
+var themes = map[string]*Theme{
+	"dark": {
+		Name:      "dark",
+		Timestamp: style{attr: "2", c16: "37", c256: "38;5;245"},
+		System:    style{attr: "1", c16: "33", c256: "38;5;214"},
+		Self:      style{attr: "1", c16: "97", c256: "38;5;231"},
+		Indicator: style{attr: "7"},
+		Mention:   style{attr: "1", c16: "30;43", c256: "38;5;16;48;5;214"},
+		Nicks: []style{
+			{c16: "31", c256: "38;5;203"},
+			{c16: "32", c256: "38;5;114"},
+			{c16: "33", c256: "38;5;221"},
+			{c16: "34", c256: "38;5;75"},
+			{c16: "35", c256: "38;5;177"},
+			{c16: "36", c256: "38;5;80"},
+			{c16: "91", c256: "38;5;209"},
+			{c16: "92", c256: "38;5;150"},
+			{c16: "94", c256: "38;5;111"},
+			{c16: "95", c256: "38;5;213"},
+			{c16: "96", c256: "38;5;123"},
+		},
+	},
+	"light": {
+		Name:      "light",
+		Timestamp: style{attr: "2", c16: "90", c256: "38;5;242"},
+		System:    style{attr: "1", c16: "35", c256: "38;5;130"},
+		Self:      style{attr: "1", c16: "30", c256: "38;5;16"},
+		Indicator: style{attr: "7"},
+		Mention:   style{attr: "1", c16: "30;43", c256: "38;5;16;48;5;222"},
+		Nicks: []style{
+			{c16: "31", c256: "38;5;124"},
+			{c16: "32", c256: "38;5;28"},
+			{c16: "33", c256: "38;5;136"},
+			{c16: "34", c256: "38;5;25"},
+			{c16: "35", c256: "38;5;91"},
+			{c16: "36", c256: "38;5;30"},
+			{c16: "31", c256: "38;5;166"},
+			{c16: "34", c256: "38;5;61"},
+			{c16: "35", c256: "38;5;127"},
+		},
+	},
+	"mono": {
+		Name:      "mono",
+		Timestamp: style{attr: "2"},
+		System:    style{attr: "1"},
+		Self:      style{attr: "1;4"},
+		Indicator: style{attr: "7"},
+		Mention:   style{attr: "1;7"},
+		Nicks:     []style{{attr: "1"}},
+	},
+}
+
+// ThemeNames는 선택할 수 있는 테마 이름을 정렬해서 반환합니다.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupTheme은 name에 해당하는 테마를 반환합니다. 없으면 기본 테마를 반환합니다.
+func lookupTheme(name string) *Theme {
+	if t, ok := themes[name]; ok {
+		return t
+	}
+	return themes[DefaultTheme]
+}
+
+// nickStyle은 username마다 항상 같은 색을 고릅니다.
+func (t *Theme) nickStyle(username string) style {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(username))
+	return t.Nicks[hash.Sum32()%uint32(len(t.Nicks))]
+}
+
+// painter는 테마와 터미널 색상 수준을 묶어 문자열에 색을 입힙니다.
+type painter struct {
+	theme *Theme
+	level colorLevel
+	self  string // 자기 자신의 username
+	clock clock
+}
+
+func (p painter) paint(s string, st style) string {
+	params := st.sgr(p.level)
+	if params == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + params + "m" + s + "\x1b[0m"
+}
+
+func (p painter) nick(username string) string {
+	if username == p.self {
+		return p.paint(username, p.theme.Self)
+	}
+	return p.paint(username, p.theme.nickStyle(username))
+}
+
+// styleHeader는 formatHeader와 같은 머리말을 색을 입혀 만듭니다. 글자 수와 칸 수는 formatHeader와 같습니다.
+func (p painter) styleHeader(msg Message) string {
+	if msg.System {
+		return p.paint("[system]", p.theme.System) + " "
+	}
+
+	ts := p.paint(p.clock.format(msg.Timestamp), p.theme.Timestamp)
+	if msg.Recipient != "" {
+		return fmt.Sprintf("[%s %s -> %s] ", ts, p.nick(msg.Username), p.nick(msg.Recipient))
+	}
+	return fmt.Sprintf("[%s %s] ", ts, p.nick(msg.Username))
+}
+
+// styleLines는 messageLines가 나눈 줄에 색을 입힙니다. 줄 바꿈은 색이 없는 상태에서 계산해야
+// 화면 너비 계산이 이스케이프 시퀀스의 영향을 받지 않습니다.
+func (p painter) styleLines(msg Message, lines []string) []string {
+	if p.level == colorNone || len(lines) == 0 {
+		return lines
+	}
+
+	styled := make([]string, len(lines))
+	copy(styled, lines)
+
+	if msg.System {
+		for i, line := range styled {
+			styled[i] = p.paint(line, p.theme.System)
+		}
+		return styled
+	}
+
+	highlight := mentions(msg, p.self)
+	header := formatHeader(msg, p.clock)
+	for i, line := range styled {
+		// 화면이 좁아 머리말이 잘린 경우에는 머리말에 색을 입히지 않습니다.
+		prefix := ""
+		if i == 0 {
+			if body, ok := strings.CutPrefix(line, header); ok {
+				prefix, line = p.styleHeader(msg), body
+			}
+		}
+		if highlight {
+			line = p.highlightMentions(line)
+		}
+		styled[i] = prefix + line
+	}
+
+	return styled
+}
+
+// saveTheme은 Client의 테마를 바꾸고 프로필에 저장합니다.
+func (h *Hub) saveTheme(c *Client, name string) error {
+	err := h.updateProfile(c, "theme", func(ctx context.Context) error {
+		return db.SetUserTheme(ctx, h.db, c.Username(), name)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.setTheme(name)
+	return nil
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "theme",
+		Usage: "/theme [" + strings.Join(ThemeNames(), "|") + "]",
+		Help:  "show or change the color theme",
+		Run: func(c *Client, args []string) error {
+			switch len(args) {
+			case 0:
+				c.systemMessage("theme: %s (available: %s)", c.themeName(), strings.Join(ThemeNames(), ", "))
+				return nil
+			case 1:
+			default:
+				return usageError(commands["theme"])
+			}
+
+			name := strings.ToLower(args[0])
+			if _, ok := themes[name]; !ok {
+				return fmt.Errorf("unknown theme %q: use %s", args[0], strings.Join(ThemeNames(), ", "))
+			}
+			hub, err := c.requireHub()
+			if err != nil {
+				return err
+			}
+			if err := hub.saveTheme(c, name); err != nil {
+				return err
+			}
+
+			c.systemMessage("theme set to %s", name)
+			return nil
+		},
+	})
+}
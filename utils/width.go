@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"regexp"
+
+	"github.com/rivo/uniseg"
+)
+
+// sgrRe는 painter가 넣는 SGR 색상 시퀀스입니다.
+var sgrRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// displayWidth는 문자열이 터미널에서 차지하는 칸 수를 반환합니다.
+// 한글, CJK, 이모지는 두 칸, 결합 문자와 ZWJ로 이어진 문자는 앞 글자와 한 덩어리로 계산합니다.
+func displayWidth(s string) int {
+	return uniseg.StringWidth(s)
+}
+
+// visibleWidth는 색상 시퀀스를 뺀 s의 칸 수를 반환합니다.
+func visibleWidth(s string) int {
+	return displayWidth(sgrRe.ReplaceAllString(s, ""))
+}
+
+// truncateWidth는 s를 앞에서부터 w칸 이하로 자릅니다. 두 칸짜리 글자가 경계에 걸치면 그 글자는 뺍니다.
+func truncateWidth(s string, w int) string {
+	used, n := 0, 0
+	rest, state := s, -1
+	for rest != "" {
+		cluster, r, width, st := uniseg.FirstGraphemeClusterInString(rest, state)
+		if used+width > w {
+			break
+		}
+		used += width
+		n += len(cluster)
+		rest, state = r, st
+	}
+
+	return s[:n]
+}
+
+// dropWidth는 s의 앞에서 최소 w칸을 잘라낸 나머지와 실제로 잘라낸 칸 수를 반환합니다.
+func dropWidth(s string, w int) (string, int) {
+	dropped := 0
+	state := -1
+	for s != "" && dropped < w {
+		var width int
+		_, s, width, state = uniseg.FirstGraphemeClusterInString(s, state)
+		dropped += width
+	}
+
+	return s, dropped
+}
+
+// graphemeBoundaries는 룬 슬라이스에서 글자 경계가 되는 룬 위치를 0과 len(r)를 포함해 반환합니다.
+func graphemeBoundaries(r []rune) []int {
+	boundaries := []int{0}
+
+	pos := 0
+	s, state := string(r), -1
+	for s != "" {
+		var cluster string
+		cluster, s, _, state = uniseg.FirstGraphemeClusterInString(s, state)
+		pos += len([]rune(cluster))
+		boundaries = append(boundaries, pos)
+	}
+
+	return boundaries
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"ascii", "hello", 5},
+		{"hangul", "안녕하세요", 10},
+		{"mixed", "hi 안녕", 7},
+		{"cjk", "漢字かな", 8},
+		{"emoji", "👍", 2},
+		{"combining", "e\u0301", 1},
+		{"zwj family", "👨‍👩‍👧", 2},
+		{"flag", "🇰🇷", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayWidth(tt.in); got != tt.want {
+				t.Errorf("displayWidth(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateMessageLinesWidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		content string
+		w       int
+		want    []string
+	}{
+		{
+			name:    "ascii fits",
+			header:  "[a] ",
+			content: "hi",
+			w:       10,
+			want:    []string{"[a] hi"},
+		},
+		{
+			name:    "hangul wraps by cells",
+			header:  "",
+			content: "가나다라마",
+			w:       4,
+			want:    []string{"가나", "다라", "마"},
+		},
+		{
+			name:    "wide char does not straddle line end",
+			header:  "[a] ",
+			content: "x가나다라",
+			w:       6,
+			want:    []string{"[a] x", "가나다", "라"},
+		},
+		{
+			name:    "combining mark stays with base",
+			header:  "",
+			content: "abe\u0301",
+			w:       2,
+			want:    []string{"ab", "e\u0301"},
+		},
+		{
+			name:    "zwj sequence kept whole",
+			header:  "",
+			content: "a👨‍👩‍👧b",
+			w:       3,
+			want:    []string{"a👨‍👩‍👧", "b"},
+		},
+		{
+			name:    "zero width",
+			header:  "[a] ",
+			content: "hi",
+			w:       0,
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateMessageLines(tt.header, tt.content, tt.w)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calculateMessageLines(%q, %q, %d) = %q, want %q", tt.header, tt.content, tt.w, got, tt.want)
+			}
+			for _, line := range got {
+				if displayWidth(line) > tt.w {
+					t.Errorf("line %q is %d cells wide, exceeds %d", line, displayWidth(line), tt.w)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateMessageLinesWordWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		content string
+		w       int
+		want    []string
+	}{
+		{
+			name:    "breaks at whitespace",
+			header:  "",
+			content: "hello world foo",
+			w:       11,
+			want:    []string{"hello world", "foo"},
+		},
+		{
+			name:    "continuation lines align under body",
+			header:  "[12:00 bob] ",
+			content: "the quick brown fox jumps over the lazy dog",
+			w:       34,
+			want: []string{
+				"[12:00 bob] the quick brown fox",
+				"            jumps over the lazy",
+				"            dog",
+			},
+		},
+		{
+			name:    "hangul words wrap at spaces",
+			header:  "[12:00 bob] ",
+			content: "안녕하세요 반갑습니다 오늘도 좋은 하루",
+			w:       34,
+			want: []string{
+				"[12:00 bob] 안녕하세요 반갑습니다",
+				"            오늘도 좋은 하루",
+			},
+		},
+		{
+			name:    "overlong token is hard broken",
+			header:  "",
+			content: "see https://example.com/abc",
+			w:       10,
+			want:    []string{"see https:", "//example.", "com/abc"},
+		},
+		{
+			name:    "trailing and repeated spaces",
+			header:  "",
+			content: "a  b   ",
+			w:       10,
+			want:    []string{"a  b"},
+		},
+		{
+			name:    "embedded newlines keep line breaks",
+			header:  "[12:00 bob] ",
+			content: "panic: boom\n\ngoroutine 1 [running]:\r\n\tmain.go:10",
+			w:       40,
+			want: []string{
+				"[12:00 bob] panic: boom",
+				"",
+				"            goroutine 1 [running]:",
+				"                main.go:10",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateMessageLines(tt.header, tt.content, tt.w)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calculateMessageLines(%q, %q, %d) = %q, want %q", tt.header, tt.content, tt.w, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateAndDropWidth(t *testing.T) {
+	if got := truncateWidth("ab한글", 3); got != "ab" {
+		t.Errorf("truncateWidth = %q, want %q", got, "ab")
+	}
+	if got := truncateWidth("ab한글", 4); got != "ab한" {
+		t.Errorf("truncateWidth = %q, want %q", got, "ab한")
+	}
+
+	rest, dropped := dropWidth("한글abc", 1)
+	if rest != "글abc" || dropped != 2 {
+		t.Errorf("dropWidth = (%q, %d), want (%q, %d)", rest, dropped, "글abc", 2)
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "plain 한글", want: "plain 한글"},
+		{in: "line\r\nnext\tcol", want: "line\nnext\tcol"},
+		{in: "\x1b[2Jclear", want: " [2Jclear"},
+		{in: "csi\u009b2J del\x7f bell\a", want: "csi 2J del  bell "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := sanitizeText(tt.in); got != tt.want {
+				t.Fatalf("sanitizeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}